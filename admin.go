@@ -0,0 +1,225 @@
+package baloon
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// adminDialect is implemented by dialects that can create and drop databases.
+type adminDialect interface {
+	dialect
+
+	// databaseExists returns whether the database exists.
+	databaseExists(db *sql.DB, database string) (bool, error)
+
+	// createDatabase creates the database if it doesn't exist.
+	createDatabase(db *sql.DB, database string) error
+
+	// dropDatabase deletes the database if it exists, disconnecting anything
+	// connected to it first.
+	dropDatabase(db *sql.DB, database string) error
+}
+
+func adminDialectFor(driver string) (adminDialect, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	adminDialect, ok := d.(adminDialect)
+	if !ok {
+		return nil, fmt.Errorf("Databases can't be created or dropped for %s databases", d.name())
+	}
+
+	return adminDialect, nil
+}
+
+// adminDatabase returns the database name to pass to an adminDialect. For SQLite this is
+// the path of the database file, which is relative to appRoot unless it's absolute.
+func adminDatabase(d adminDialect, database string, appRoot string) string {
+	if _, ok := d.(sqliteDialect); ok && !filepath.IsAbs(database) {
+		return filepath.Join(appRoot, database)
+	}
+
+	return database
+}
+
+// runAdminScript creates or drops the database named by a ScriptTypeCreateDatabase or
+// ScriptTypeDropDatabase Script.
+func runAdminScript(db *sql.DB, driver string, script Script, appRoot string) error {
+	d, err := adminDialectFor(driver)
+	if err != nil {
+		return err
+	}
+
+	database := adminDatabase(d, script.Command, appRoot)
+
+	if script.Type == ScriptTypeCreateDatabase {
+		return d.createDatabase(db, database)
+	}
+
+	return d.dropDatabase(db, database)
+}
+
+// DatabaseExists connects to the database server with conn and checks whether the database
+// with the given name exists. For SQLite, name is the path of the database file, relative
+// to AppRoot unless it's absolute, as in NewCreateDatabaseScript.
+func (fixture *Fixture) DatabaseExists(conn DBConn, name string) (bool, error) {
+	d, err := adminDialectFor(conn.Driver)
+	if err != nil {
+		return false, err
+	}
+
+	db, err := conn.connect()
+	if err != nil {
+		return false, fixture.redactor.err(fmt.Errorf("Error connecting to database: %s", err.Error()))
+	}
+	defer db.Close()
+
+	exists, err := d.databaseExists(db, adminDatabase(d, name, fixture.config.AppRoot))
+	return exists, fixture.redactor.err(err)
+}
+
+func (postgresDialect) databaseExists(db *sql.DB, database string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = " + quoteString(database) + ")").Scan(&exists)
+	return exists, err
+}
+
+func (d postgresDialect) createDatabase(db *sql.DB, database string) error {
+	// Postgres has no CREATE DATABASE IF NOT EXISTS
+	exists, err := d.databaseExists(db, database)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec("CREATE DATABASE " + d.quote(database) + ";")
+	return err
+}
+
+func (d postgresDialect) dropDatabase(db *sql.DB, database string) error {
+	var version int
+	err := db.QueryRow("SELECT current_setting('server_version_num')::int").Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	// WITH (FORCE) terminates other connections, but needs Postgres 13+
+	if version >= 130000 {
+		_, err = db.Exec("DROP DATABASE IF EXISTS " + d.quote(database) + " WITH (FORCE);")
+		return err
+	}
+
+	exists, err := d.databaseExists(db, database)
+	if err != nil || !exists {
+		return err
+	}
+
+	// stop new connections, so none are made between terminating the others and dropping
+	_, err = db.Exec("ALTER DATABASE " + d.quote(database) + " ALLOW_CONNECTIONS false;")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = " + quoteString(database) + " AND pid <> pg_backend_pid();")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DROP DATABASE " + d.quote(database) + ";")
+	return err
+}
+
+func (mysqlDialect) databaseExists(db *sql.DB, database string) (bool, error) {
+	var count int
+	// a parameter, as MySQL treats backslashes in string literals as escapes, unless
+	// NO_BACKSLASH_ESCAPES is set
+	err := db.QueryRow("SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = ?", database).Scan(&count)
+	return count > 0, err
+}
+
+func (d mysqlDialect) createDatabase(db *sql.DB, database string) error {
+	_, err := db.Exec("CREATE DATABASE IF NOT EXISTS " + d.quote(database) + ";")
+	return err
+}
+
+func (d mysqlDialect) dropDatabase(db *sql.DB, database string) error {
+	// kill other connections, so DROP DATABASE doesn't wait on their locks
+	rows, err := db.Query("SELECT id FROM information_schema.processlist WHERE db = ? AND id <> CONNECTION_ID()", database)
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		// the connection may have already gone
+		db.Exec(fmt.Sprintf("KILL %d;", id))
+	}
+
+	_, err = db.Exec("DROP DATABASE IF EXISTS " + d.quote(database) + ";")
+	return err
+}
+
+func (sqliteDialect) databaseExists(db *sql.DB, database string) (bool, error) {
+	_, err := os.Stat(database)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (sqliteDialect) createDatabase(db *sql.DB, database string) error {
+	// an empty file is an empty SQLite database
+	file, err := os.OpenFile(database, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+func (sqliteDialect) dropDatabase(db *sql.DB, database string) error {
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		err := os.Remove(database + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sqlserverDialect) databaseExists(db *sql.DB, database string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT CAST(CASE WHEN DB_ID(N" + quoteString(database) + ") IS NULL THEN 0 ELSE 1 END AS BIT)").Scan(&exists)
+	return exists, err
+}
+
+func (d sqlserverDialect) createDatabase(db *sql.DB, database string) error {
+	_, err := db.Exec("IF DB_ID(N" + quoteString(database) + ") IS NULL CREATE DATABASE " + d.quote(database) + ";")
+	return err
+}
+
+func (d sqlserverDialect) dropDatabase(db *sql.DB, database string) error {
+	// SINGLE_USER with ROLLBACK IMMEDIATE disconnects everything else
+	_, err := db.Exec("IF DB_ID(N" + quoteString(database) + ") IS NOT NULL BEGIN " +
+		"ALTER DATABASE " + d.quote(database) + " SET SINGLE_USER WITH ROLLBACK IMMEDIATE; " +
+		"DROP DATABASE " + d.quote(database) + "; END")
+	return err
+}
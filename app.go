@@ -0,0 +1,400 @@
+package baloon
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// coverDirPattern is the os.MkdirTemp pattern for the directories Apps write coverage data to.
+const coverDirPattern = "baloon_cover_"
+
+// appRunner builds and runs an App executable, capturing its output for the lifetime of the Fixture.
+type appRunner struct {
+	app     App
+	index   int
+	root    string
+	binPath string
+
+	// guards port and url, which are read by tests while the App restarts
+	mu   sync.Mutex
+	port int
+	url  string
+
+	cmd    *exec.Cmd
+	exited chan struct{}
+	output *appOutput
+
+	coverDir string
+	journal  *journal
+
+	// in-process mode
+	server *httptest.Server
+	cancel context.CancelFunc
+}
+
+func newAppRunner(app App, root string) *appRunner {
+	return &appRunner{
+		app:    app,
+		root:   root,
+		output: &appOutput{},
+	}
+}
+
+// instance returns a runner for another instance of the App, using the same executable
+// and coverage data directory, but allocated its own port.
+func (runner *appRunner) instance() *appRunner {
+	app := runner.app
+	app.Port = 0
+
+	instance := newAppRunner(app, runner.root)
+	instance.index = runner.index
+	instance.binPath = runner.binPath
+	instance.coverDir = runner.coverDir
+	instance.journal = runner.journal
+	return instance
+}
+
+// build compiles the App executable into the App root directory.
+func (runner *appRunner) build() error {
+	if runner.app.InProcess {
+		return nil
+	}
+
+	buildArgs := runner.app.BuildArguments
+
+	containsOutputArg := false
+	containsBuildArg := false
+	containsRaceArg := false
+	containsCoverArg := false
+
+	for i, arg := range buildArgs {
+		name, value, hasValue := splitBuildFlag(arg)
+
+		if name == "-o" {
+			containsOutputArg = true
+			if hasValue {
+				runner.binPath = value
+			} else if i+1 < len(buildArgs) {
+				runner.binPath = buildArgs[i+1]
+			}
+		}
+		if arg == "build" {
+			containsBuildArg = true
+		}
+		if name == "-race" {
+			containsRaceArg = true
+		}
+		if name == "-cover" || name == "-coverpkg" {
+			containsCoverArg = true
+		}
+	}
+
+	if runner.binPath == "" {
+		runner.binPath = "./" + path.Base(runner.root) + "_" + randomCharacters(8)
+	}
+
+	var flags []string
+
+	if runner.app.Race && !containsRaceArg {
+		flags = append(flags, "-race")
+	}
+
+	if runner.app.Cover && !containsCoverArg {
+		flags = append(flags, "-cover")
+		if len(runner.app.CoverPackages) > 0 {
+			flags = append(flags, "-coverpkg="+strings.Join(runner.app.CoverPackages, ","))
+		}
+	}
+
+	if !containsOutputArg {
+		flags = append(flags, "-o", runner.binPath)
+	}
+
+	// flags go straight after "build" so they come before any package arguments
+	if !containsBuildArg {
+		buildArgs = append([]string{"build"}, buildArgs...)
+	}
+
+	for i, arg := range buildArgs {
+		if arg == "build" {
+			args := append([]string{}, buildArgs[:i+1]...)
+			args = append(args, flags...)
+			buildArgs = append(args, buildArgs[i+1:]...)
+			break
+		}
+	}
+
+	runner.journal.file(runner.binaryPath())
+
+	cmd := exec.Command("go", buildArgs...)
+	cmd.Dir = runner.root
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Error building program%s: %s\n%s", runner.describe(), err.Error(), output)
+	}
+
+	return nil
+}
+
+// allocate assigns the App its port and URL, so other Apps can reference them before it has started.
+func (runner *appRunner) allocate(funcs template.FuncMap) error {
+	// httptest.Server picks its own port
+	if runner.app.InProcess && runner.app.Handler != nil {
+		return nil
+	}
+
+	port := runner.app.Port
+	if port == 0 {
+		var err error
+		port, err = freePort()
+		if err != nil {
+			return fmt.Errorf("Error finding a free port for program under test: %s", err.Error())
+		}
+	}
+
+	runner.setAddress(port, fmt.Sprintf("http://localhost:%d", port))
+
+	if runner.app.BaseURL != "" && !runner.app.InProcess {
+		url, err := expandTemplate(runner.app.BaseURL, funcs, runner.templateData())
+		if err != nil {
+			return err
+		}
+		runner.setAddress(port, url)
+	}
+
+	return nil
+}
+
+// setAddress sets the port the App listens on, and the URL it serves requests on.
+func (runner *appRunner) setAddress(port int, url string) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	runner.port = port
+	runner.url = url
+}
+
+// listenPort returns the port the App listens on.
+func (runner *appRunner) listenPort() int {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	return runner.port
+}
+
+// describe returns the App name for use in error messages, if it has one.
+func (runner *appRunner) describe() string {
+	if runner.app.Name == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" \"%s\"", runner.app.Name)
+}
+
+func (runner *appRunner) templateData() appTemplateData {
+	return appTemplateData{
+		Name: runner.app.Name,
+		Port: runner.listenPort(),
+		URL:  runner.baseURL(),
+	}
+}
+
+// start runs the built App executable and waits for it to write the WaitForOutputLine.
+// Any RunArguments or Env in options are used in place of those in the App's settings.
+func (runner *appRunner) start(funcs template.FuncMap, options AppOptions) error {
+	if runner.app.InProcess {
+		return runner.startInProcess()
+	}
+
+	runArguments := runner.app.RunArguments
+	if options.RunArguments != nil {
+		runArguments = options.RunArguments
+	}
+
+	var args []string
+	for _, arg := range runArguments {
+		arg, err := expandTemplate(arg, funcs, runner.templateData())
+		if err != nil {
+			return err
+		}
+		args = append(args, arg)
+	}
+
+	env := os.Environ()
+	for _, variable := range append(append([]string{}, runner.app.Env...), options.Env...) {
+		variable, err := expandTemplate(variable, funcs, runner.templateData())
+		if err != nil {
+			return err
+		}
+		env = append(env, variable)
+	}
+
+	if runner.app.Cover && runner.coverDir == "" {
+		coverDir, err := os.MkdirTemp("", coverDirPattern)
+		if err != nil {
+			return fmt.Errorf("Error creating coverage data directory: %s", err.Error())
+		}
+		runner.coverDir = coverDir
+		runner.journal.tempDir(coverDir)
+	}
+
+	cmd := exec.Command(runner.binaryPath(), args...)
+	cmd.Dir = runner.root
+	cmd.Stdout = &lineWriter{output: runner.output}
+	cmd.Stderr = &lineWriter{output: runner.output}
+	cmd.WaitDelay = time.Second
+	cmd.Env = env
+	setProcessAttributes(cmd)
+
+	if runner.coverDir != "" {
+		cmd.Env = append(cmd.Env, "GOCOVERDIR="+runner.coverDir)
+	}
+
+	ready := runner.output.watch(runner.app.WaitForOutputLine)
+
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("Error running program under test%s: %s", runner.describe(), err.Error())
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	runner.cmd = cmd
+	runner.exited = exited
+	runner.journal.process(cmd.Process.Pid, runner.binaryPath())
+
+	select {
+	case <-ready:
+		return nil
+	case <-time.After(runner.app.WaitTimeout):
+		return newSentinelError(ErrStartupTimeout, "Timeout waiting for program%s to start. Was looking for output line \"%s\".",
+			runner.describe(), runner.app.WaitForOutputLine)
+	}
+}
+
+// running reports whether the App process has been started and not yet exited.
+func (runner *appRunner) running() bool {
+	if runner.app.InProcess {
+		return runner.server != nil || runner.cancel != nil
+	}
+
+	if runner.cmd == nil || runner.cmd.Process == nil {
+		return false
+	}
+
+	select {
+	case <-runner.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop asks the App to shut down gracefully with an interrupt signal, so that
+// things like coverage data get written, and kills it if it doesn't exit in time.
+func (runner *appRunner) stop() error {
+	if runner.app.InProcess {
+		return runner.stopInProcess(runner.app.ShutdownTimeout)
+	}
+
+	if !runner.running() {
+		return runner.kill()
+	}
+
+	// interrupts aren't supported on Windows, so just kill it
+	err := signalProcess(runner.cmd, os.Interrupt)
+	if err != nil {
+		return runner.kill()
+	}
+
+	select {
+	case <-runner.exited:
+		return nil
+	case <-time.After(runner.app.ShutdownTimeout):
+		return runner.kill()
+	}
+}
+
+// kill terminates the App process, and any processes it started, immediately.
+func (runner *appRunner) kill() error {
+	if runner.app.InProcess {
+		return runner.stopInProcess(0)
+	}
+
+	if runner.cmd == nil || runner.cmd.Process == nil {
+		return nil
+	}
+
+	// processes the App started may still be running after it has exited
+	err := killProcess(runner.cmd)
+	if err != nil && runner.running() {
+		return err
+	}
+
+	<-runner.exited
+	return nil
+}
+
+// baseURL returns the root URL the App is serving HTTP requests on.
+func (runner *appRunner) baseURL() string {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	return runner.url
+}
+
+// binaryPath returns the absolute path of the compiled App executable.
+func (runner *appRunner) binaryPath() string {
+	if filepath.IsAbs(runner.binPath) {
+		return runner.binPath
+	}
+
+	return filepath.Join(runner.root, runner.binPath)
+}
+
+// removeBinary deletes the compiled App executable.
+func (runner *appRunner) removeBinary() error {
+	if runner.binPath == "" {
+		return nil
+	}
+
+	fullAppPath := runner.binaryPath()
+
+	_, err := os.Stat(fullAppPath)
+	if err == nil {
+		return os.Remove(fullAppPath)
+	}
+
+	return nil
+}
+
+// splitBuildFlag splits a "go build" argument into the flag name, with a single leading
+// dash, and any value given with "=", e.g. "--o=bin/app" is "-o" and "bin/app". Arguments
+// that aren't flags are returned as they are.
+func splitBuildFlag(arg string) (name string, value string, hasValue bool) {
+	if !strings.HasPrefix(arg, "-") {
+		return arg, "", false
+	}
+
+	name = "-" + strings.TrimLeft(arg, "-")
+
+	if equals := strings.Index(name, "="); equals != -1 {
+		return name[:equals], name[equals+1:], true
+	}
+
+	return name, "", false
+}
@@ -0,0 +1,151 @@
+package baloon
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// orderApps returns runners for the Apps in a FixtureConfig, sorted so that each App
+// comes after the Apps it depends on. Otherwise Apps keep the order they were given.
+// Any problems with the Apps' names or dependencies are added to problems.
+func orderApps(config FixtureConfig, problems *problems) []*appRunner {
+	apps := config.Apps
+	if len(apps) == 0 {
+		apps = []App{config.AppSetup}
+	}
+
+	before := len(problems.errors)
+
+	byName := map[string]int{}
+	for i, app := range apps {
+		if len(apps) > 1 && app.Name == "" {
+			problems.addf(fmt.Sprintf("Apps[%d].Name", i), "Apps[%d].Name has not been set", i)
+			continue
+		}
+
+		if _, exists := byName[app.Name]; exists {
+			problems.addf(fmt.Sprintf("Apps[%d].Name", i), "Apps[%d].Name \"%s\" is used by more than one App", i, app.Name)
+			continue
+		}
+		byName[app.Name] = i
+	}
+
+	for i, app := range apps {
+		for _, dependency := range app.DependsOn {
+			if _, exists := byName[dependency]; !exists {
+				problems.addf(fmt.Sprintf("Apps[%d].DependsOn", i), "Apps[%d].DependsOn references unknown App \"%s\"", i, dependency)
+			}
+		}
+	}
+
+	// the Apps can't be ordered without their names
+	if len(problems.errors) > before {
+		return nil
+	}
+
+	var ordered []*appRunner
+	visited := make([]bool, len(apps))
+	visiting := make([]bool, len(apps))
+
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		if visited[i] {
+			return nil
+		}
+
+		path = append(path, apps[i].Name)
+		if visiting[i] {
+			return newConfigError("Apps", "Apps have a circular dependency: %s", strings.Join(path, " -> "))
+		}
+		visiting[i] = true
+
+		for _, dependency := range apps[i].DependsOn {
+			err := visit(byName[dependency], path)
+			if err != nil {
+				return err
+			}
+		}
+
+		visiting[i] = false
+		visited[i] = true
+
+		root := config.AppRoot
+		if apps[i].Root != "" {
+			root = apps[i].Root
+			if !filepath.IsAbs(root) {
+				root = filepath.Join(config.AppRoot, root)
+			}
+		}
+
+		runner := newAppRunner(apps[i], root)
+		runner.index = i
+		ordered = append(ordered, runner)
+
+		return nil
+	}
+
+	for i := range apps {
+		err := visit(i, nil)
+		if err != nil {
+			problems.add(err)
+
+			// skip the Apps in the cycle, so it's only reported once
+			for j := range visiting {
+				if visiting[j] {
+					visiting[j] = false
+					visited[j] = true
+				}
+			}
+		}
+	}
+
+	if len(problems.errors) > before {
+		return nil
+	}
+
+	return ordered
+}
+
+// primaryApp returns the first App given in the FixtureConfig.
+func (fixture *Fixture) primaryApp() *appRunner {
+	return firstApp(fixture.apps)
+}
+
+// appNamed returns the App with the given name.
+func (fixture *Fixture) appNamed(name string) (*appRunner, error) {
+	return appNamed(fixture.apps, name)
+}
+
+// findApp returns the App with the given name, or the first App if name is empty.
+func (fixture *Fixture) findApp(name string) (*appRunner, error) {
+	if name == "" {
+		if app := fixture.primaryApp(); app != nil {
+			return app, nil
+		}
+	}
+
+	return fixture.appNamed(name)
+}
+
+// firstApp returns the runner for the first App given in the FixtureConfig.
+func firstApp(apps []*appRunner) *appRunner {
+	for _, runner := range apps {
+		if runner.index == 0 {
+			return runner
+		}
+	}
+
+	return nil
+}
+
+// appNamed returns the runner for the App with the given name.
+func appNamed(apps []*appRunner, name string) (*appRunner, error) {
+	for _, runner := range apps {
+		if runner.app.Name == name {
+			return runner, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No App named \"%s\"", name)
+}
@@ -0,0 +1,329 @@
+package baloon
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// table is a database table, optionally qualified by a schema.
+type table struct {
+	schema string
+	name   string
+}
+
+// quoted returns the table name, qualified by schema if set, quoted using the dialect.
+func (t table) quoted(d dialect) string {
+	if t.schema == "" {
+		return d.quote(t.name)
+	}
+
+	return d.quote(t.schema) + "." + d.quote(t.name)
+}
+
+// matches reports whether name refers to this table, either with or without the schema.
+func (t table) matches(name string) bool {
+	if strings.EqualFold(name, t.name) {
+		return true
+	}
+
+	return t.schema != "" && strings.EqualFold(name, t.schema+"."+t.name)
+}
+
+// foreignKey is a reference from one table to another.
+type foreignKey struct {
+	from table
+	to   table
+}
+
+// cleanDialect is implemented by dialects that can delete all data from tables.
+type cleanDialect interface {
+	dialect
+
+	// tables returns all user tables in the database.
+	tables(db *sql.DB) ([]table, error)
+
+	// foreignKeys returns all foreign keys between tables in the database.
+	foreignKeys(db *sql.DB) ([]foreignKey, error)
+
+	// cleanTables deletes all rows from tables, which are ordered so that tables
+	// come before any tables they reference, and resets identity columns.
+	cleanTables(db *sql.DB, tables []table) error
+}
+
+// cleanTables deletes all rows from every table in the database, except those excluded.
+func cleanTables(db *sql.DB, driver string, exclude []string) error {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return err
+	}
+
+	cleaner, ok := d.(cleanDialect)
+	if !ok {
+		return fmt.Errorf("Cleaning tables isn't supported for %s databases", d.name())
+	}
+
+	allTables, err := cleaner.tables(db)
+	if err != nil {
+		return fmt.Errorf("Error getting tables: %s", err.Error())
+	}
+
+	tables := excludeTables(allTables, exclude)
+	if len(tables) == 0 {
+		return nil
+	}
+
+	foreignKeys, err := cleaner.foreignKeys(db)
+	if err != nil {
+		return fmt.Errorf("Error getting foreign keys: %s", err.Error())
+	}
+
+	return cleaner.cleanTables(db, orderTables(tables, foreignKeys))
+}
+
+// orderTables sorts tables so that each table comes before any tables it references,
+// so rows can be deleted without breaking foreign key constraints. Tables in a cycle
+// of references keep their original order.
+func orderTables(tables []table, foreignKeys []foreignKey) []table {
+	included := map[table]bool{}
+	for _, t := range tables {
+		included[t] = true
+	}
+
+	// references[t] is the number of other tables, not yet ordered, that reference t
+	references := map[table]int{}
+	for _, fk := range foreignKeys {
+		if fk.from != fk.to && included[fk.from] {
+			references[fk.to]++
+		}
+	}
+
+	var ordered []table
+	remaining := append([]table{}, tables...)
+
+	for len(remaining) > 0 {
+		next := -1
+		for i, t := range remaining {
+			if references[t] == 0 {
+				next = i
+				break
+			}
+		}
+
+		// circular references, so just use the original order
+		if next < 0 {
+			next = 0
+		}
+
+		t := remaining[next]
+		ordered = append(ordered, t)
+		remaining = append(remaining[:next], remaining[next+1:]...)
+
+		for _, fk := range foreignKeys {
+			if fk.from == t && fk.to != t && included[fk.from] {
+				references[fk.to]--
+			}
+		}
+	}
+
+	return ordered
+}
+
+// queryTables runs a query returning a schema and table name for each row.
+func queryTables(db *sql.DB, query string) ([]table, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []table
+	for rows.Next() {
+		var t table
+		err := rows.Scan(&t.schema, &t.name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+
+	return tables, rows.Err()
+}
+
+// queryForeignKeys runs a query returning the schema and table name of the referencing
+// table, followed by the schema and table name of the referenced table, for each row.
+func queryForeignKeys(db *sql.DB, query string) ([]foreignKey, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var foreignKeys []foreignKey
+	for rows.Next() {
+		var fk foreignKey
+		err := rows.Scan(&fk.from.schema, &fk.from.name, &fk.to.schema, &fk.to.name)
+		if err != nil {
+			return nil, err
+		}
+		foreignKeys = append(foreignKeys, fk)
+	}
+
+	return foreignKeys, rows.Err()
+}
+
+func (postgresDialect) tables(db *sql.DB) ([]table, error) {
+	return queryTables(db, `
+		SELECT table_schema, table_name
+		FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE'
+			AND table_schema NOT IN ('pg_catalog', 'information_schema', '`+postgresTrackingSchema+`')
+		ORDER BY table_schema, table_name`)
+}
+
+func (postgresDialect) foreignKeys(db *sql.DB) ([]foreignKey, error) {
+	return queryForeignKeys(db, `
+		SELECT tc.table_schema, tc.table_name, ccu.table_schema, ccu.table_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.constraint_column_usage ccu
+			ON tc.constraint_schema = ccu.constraint_schema
+			AND tc.constraint_name = ccu.constraint_name
+		WHERE tc.constraint_type = 'FOREIGN KEY'`)
+}
+
+func (d postgresDialect) cleanTables(db *sql.DB, tables []table) error {
+	// a single TRUNCATE doesn't need to worry about the order of tables
+	var names []string
+	for _, t := range tables {
+		names = append(names, t.quoted(d))
+	}
+
+	_, err := db.Exec("TRUNCATE TABLE " + strings.Join(names, ", ") + " RESTART IDENTITY;")
+	return err
+}
+
+func (mysqlDialect) tables(db *sql.DB) ([]table, error) {
+	return queryTables(db, `
+		SELECT '', table_name
+		FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE'
+			AND table_schema = DATABASE()
+		ORDER BY table_name`)
+}
+
+func (mysqlDialect) foreignKeys(db *sql.DB) ([]foreignKey, error) {
+	return queryForeignKeys(db, `
+		SELECT '', table_name, '', referenced_table_name
+		FROM information_schema.key_column_usage
+		WHERE table_schema = DATABASE()
+			AND referenced_table_name IS NOT NULL`)
+}
+
+func (d mysqlDialect) cleanTables(db *sql.DB, tables []table) error {
+	for _, t := range tables {
+		_, err := db.Exec("DELETE FROM " + t.quoted(d) + ";")
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec("ALTER TABLE " + t.quoted(d) + " AUTO_INCREMENT = 1;")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sqliteDialect) tables(db *sql.DB) ([]table, error) {
+	return queryTables(db, `
+		SELECT '', name
+		FROM sqlite_master
+		WHERE type = 'table'
+			AND name NOT LIKE 'sqlite_%'
+			AND name NOT LIKE 'baloon\_%' ESCAPE '\'
+		ORDER BY name`)
+}
+
+func (d sqliteDialect) foreignKeys(db *sql.DB) ([]foreignKey, error) {
+	tables, err := d.tables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var foreignKeys []foreignKey
+	for _, t := range tables {
+		fks, err := queryForeignKeys(db, `
+			SELECT '', `+quoteString(t.name)+`, '', "table"
+			FROM pragma_foreign_key_list(`+quoteString(t.name)+`)`)
+		if err != nil {
+			return nil, err
+		}
+		foreignKeys = append(foreignKeys, fks...)
+	}
+
+	return foreignKeys, nil
+}
+
+func (d sqliteDialect) cleanTables(db *sql.DB, tables []table) error {
+	var names []string
+	for _, t := range tables {
+		_, err := db.Exec("DELETE FROM " + t.quoted(d) + ";")
+		if err != nil {
+			return err
+		}
+		names = append(names, quoteString(t.name))
+	}
+
+	// sqlite_sequence only exists if a table uses AUTOINCREMENT
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_sequence'").Scan(&exists)
+	if err != nil || exists == 0 {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM sqlite_sequence WHERE name IN (" + strings.Join(names, ", ") + ");")
+	return err
+}
+
+func (sqlserverDialect) tables(db *sql.DB) ([]table, error) {
+	return queryTables(db, `
+		SELECT TABLE_SCHEMA, TABLE_NAME
+		FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_TYPE = 'BASE TABLE'
+		ORDER BY TABLE_SCHEMA, TABLE_NAME`)
+}
+
+func (sqlserverDialect) foreignKeys(db *sql.DB) ([]foreignKey, error) {
+	return queryForeignKeys(db, `
+		SELECT OBJECT_SCHEMA_NAME(parent_object_id), OBJECT_NAME(parent_object_id),
+			OBJECT_SCHEMA_NAME(referenced_object_id), OBJECT_NAME(referenced_object_id)
+		FROM sys.foreign_keys`)
+}
+
+func (d sqlserverDialect) cleanTables(db *sql.DB, tables []table) error {
+	for _, t := range tables {
+		_, err := db.Exec("DELETE FROM " + t.quoted(d) + ";")
+		if err != nil {
+			return err
+		}
+
+		// only reseed identity columns that have been used, otherwise the next value would be 0
+		name := quoteString(t.quoted(d))
+		_, err = db.Exec("IF EXISTS (SELECT 1 FROM sys.identity_columns " +
+			"WHERE object_id = OBJECT_ID(" + name + ") AND last_value IS NOT NULL) " +
+			"DBCC CHECKIDENT (" + name + ", RESEED, 0);")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// quoteString returns text as a quoted SQL string literal. It isn't used for MySQL, which
+// treats backslashes as escapes unless NO_BACKSLASH_ESCAPES is set, so MySQL queries pass
+// values as parameters instead.
+func quoteString(text string) string {
+	return "'" + strings.Replace(text, "'", "''", -1) + "'"
+}
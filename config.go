@@ -0,0 +1,381 @@
+package baloon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the layout of a YAML or TOML config file read by LoadConfig.
+type configFile struct {
+	AppRoot           string                `yaml:"app_root" toml:"app_root"`
+	Variables         map[string]string     `yaml:"variables" toml:"variables"`
+	Secrets           []string              `yaml:"secrets" toml:"secrets"`
+	DatabaseSetups    []dbFile              `yaml:"database_setups" toml:"database_setups"`
+	App               *appFile              `yaml:"app" toml:"app"`
+	Apps              []appFile             `yaml:"apps" toml:"apps"`
+	UnitTestSetups    []unitTestFile        `yaml:"unit_test_setups" toml:"unit_test_setups"`
+	UnitTestTeardowns []unitTestFile        `yaml:"unit_test_teardowns" toml:"unit_test_teardowns"`
+	DatabaseTeardowns []dbFile              `yaml:"database_teardowns" toml:"database_teardowns"`
+	Profiles          map[string]configFile `yaml:"profiles" toml:"profiles"`
+	Profile           string                `yaml:"profile" toml:"profile"`
+}
+
+// dbFile is a DB in a config file.
+type dbFile struct {
+	Connection connFile     `yaml:"connection" toml:"connection"`
+	Scripts    []scriptFile `yaml:"scripts" toml:"scripts"`
+}
+
+// connFile is a DBConn in a config file.
+type connFile struct {
+	Driver      string `yaml:"driver" toml:"driver"`
+	String      string `yaml:"string" toml:"string"`
+	Host        string `yaml:"host" toml:"host"`
+	Port        int    `yaml:"port" toml:"port"`
+	User        string `yaml:"user" toml:"user"`
+	Password    string `yaml:"password" toml:"password"`
+	Database    string `yaml:"database" toml:"database"`
+	Params      string `yaml:"params" toml:"params"`
+	WaitTimeout string `yaml:"wait_timeout" toml:"wait_timeout"`
+}
+
+// scriptFile is a Script in a config file. Exactly one of SQL, Path, CleanTables,
+// CreateDatabase or DropDatabase is set, and Template can be set with SQL or Path.
+type scriptFile struct {
+	SQL            string   `yaml:"sql" toml:"sql"`
+	Path           string   `yaml:"path" toml:"path"`
+	CleanTables    bool     `yaml:"clean_tables" toml:"clean_tables"`
+	Exclude        []string `yaml:"exclude" toml:"exclude"`
+	CreateDatabase string   `yaml:"create_database" toml:"create_database"`
+	DropDatabase   string   `yaml:"drop_database" toml:"drop_database"`
+	Template       bool     `yaml:"template" toml:"template"`
+}
+
+// appFile is an App in a config file.
+type appFile struct {
+	Name              string   `yaml:"name" toml:"name"`
+	Root              string   `yaml:"root" toml:"root"`
+	InProcess         bool     `yaml:"in_process" toml:"in_process"`
+	DependsOn         []string `yaml:"depends_on" toml:"depends_on"`
+	Port              int      `yaml:"port" toml:"port"`
+	BuildArguments    []string `yaml:"build_arguments" toml:"build_arguments"`
+	RunArguments      []string `yaml:"run_arguments" toml:"run_arguments"`
+	Env               []string `yaml:"env" toml:"env"`
+	WaitForOutputLine string   `yaml:"wait_for_output_line" toml:"wait_for_output_line"`
+	WaitTimeout       string   `yaml:"wait_timeout" toml:"wait_timeout"`
+	BaseURL           string   `yaml:"base_url" toml:"base_url"`
+	ShutdownTimeout   string   `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	Race              bool     `yaml:"race" toml:"race"`
+	Cover             bool     `yaml:"cover" toml:"cover"`
+	CoverPackages     []string `yaml:"cover_packages" toml:"cover_packages"`
+	CoverProfile      string   `yaml:"cover_profile" toml:"cover_profile"`
+}
+
+// unitTestFile is a UnitTest in a config file.
+type unitTestFile struct {
+	DatabaseRoutines []dbFile `yaml:"database_routines" toml:"database_routines"`
+}
+
+// LoadConfig reads a FixtureConfig from a YAML (.yaml or .yml) or TOML (.toml) file. Only
+// the file itself is checked, e.g. for unknown settings and invalid durations, and the
+// rest is left to NewFixture. Relative paths in the file, including 'app_root', are
+// relative to the directory the file is in. Values can use ${NAME} for the environment
+// variable NAME, or ${NAME:-default} for a default when NAME isn't set. Profiles are
+// written under 'profiles', using the same settings as the rest of the file. Environment
+// variables used only in profiles that aren't selected don't have to be set.
+//
+// Settings that can't be written in a file, such as an InProcess App's Handler or a
+// UnitTest's Func, can be added to the returned FixtureConfig before calling NewFixture.
+func LoadConfig(path string) (FixtureConfig, error) {
+	var file configFile
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FixtureConfig{}, fmt.Errorf("Error reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+
+		err = decoder.Decode(&file)
+		if err == io.EOF {
+			err = nil
+		}
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), &file)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown settings %v", meta.Undecoded())
+		}
+	default:
+		return FixtureConfig{}, fmt.Errorf("Unsupported config file \"%s\", use a .yaml, .yml or .toml file", path)
+	}
+
+	if err != nil {
+		return FixtureConfig{}, fmt.Errorf("Error parsing config file \"%s\": %w", path, err)
+	}
+
+	// profiles are interpolated separately, as only the selected profile needs its
+	// environment variables set
+	profiles := file.Profiles
+	file.Profiles = nil
+
+	missing := map[string]bool{}
+	interpolateEnv(reflect.ValueOf(&file).Elem(), missing)
+	selected := FixtureConfig{Profile: file.Profile}.selectedProfile()
+
+	for name, profile := range profiles {
+		if name == selected {
+			interpolateEnv(reflect.ValueOf(&profile).Elem(), missing)
+		} else {
+			interpolateEnv(reflect.ValueOf(&profile).Elem(), map[string]bool{})
+		}
+		profiles[name] = profile
+	}
+	file.Profiles = profiles
+
+	if len(missing) > 0 {
+		var names []string
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return FixtureConfig{}, fmt.Errorf("Environment variables used in config file have not been set: %s", strings.Join(names, ", "))
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return FixtureConfig{}, fmt.Errorf("Error resolving config file directory: %w", err)
+	}
+
+	return file.config(dir, "")
+}
+
+// envPattern matches ${NAME} and ${NAME:-default} in config file values.
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolateEnv replaces environment variable references in every string in value, and
+// adds the names of environment variables that aren't set and have no default to missing.
+// Those references are left as they are.
+func interpolateEnv(value reflect.Value, missing map[string]bool) {
+	var walk func(value reflect.Value)
+	walk = func(value reflect.Value) {
+		switch value.Kind() {
+		case reflect.String:
+			value.SetString(envPattern.ReplaceAllStringFunc(value.String(), func(match string) string {
+				groups := envPattern.FindStringSubmatch(match)
+				if env, ok := os.LookupEnv(groups[1]); ok {
+					return env
+				}
+				if groups[2] == "" {
+					missing[groups[1]] = true
+					return match
+				}
+				return groups[3]
+			}))
+		case reflect.Ptr:
+			if !value.IsNil() {
+				walk(value.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < value.NumField(); i++ {
+				walk(value.Field(i))
+			}
+		case reflect.Slice:
+			for i := 0; i < value.Len(); i++ {
+				walk(value.Index(i))
+			}
+		case reflect.Map:
+			for _, key := range value.MapKeys() {
+				element := reflect.New(value.Type().Elem()).Elem()
+				element.Set(value.MapIndex(key))
+				walk(element)
+				value.SetMapIndex(key, element)
+			}
+		}
+	}
+
+	walk(value)
+}
+
+// config converts a config file to a FixtureConfig, with relative paths resolved from dir.
+// Fields in errors start with prefix, for profiles.
+func (file configFile) config(dir string, prefix string) (FixtureConfig, error) {
+	var problems problems
+
+	config := FixtureConfig{
+		AppRoot:   resolvePath(dir, file.AppRoot),
+		Variables: file.Variables,
+		Secrets:   file.Secrets,
+		Profile:   file.Profile,
+	}
+
+	for i, db := range file.DatabaseSetups {
+		config.DatabaseSetups = append(config.DatabaseSetups, db.db(dir, fmt.Sprintf("%sDatabaseSetups[%d]", prefix, i), &problems))
+	}
+
+	for i, db := range file.DatabaseTeardowns {
+		config.DatabaseTeardowns = append(config.DatabaseTeardowns, db.db(dir, fmt.Sprintf("%sDatabaseTeardowns[%d]", prefix, i), &problems))
+	}
+
+	if file.App != nil {
+		config.AppSetup = file.App.app(dir, prefix+"AppSetup", &problems)
+	}
+
+	for i, app := range file.Apps {
+		config.Apps = append(config.Apps, app.app(dir, fmt.Sprintf("%sApps[%d]", prefix, i), &problems))
+	}
+
+	for i, unitTest := range file.UnitTestSetups {
+		config.UnitTestSetups = append(config.UnitTestSetups, unitTest.unitTest(dir, fmt.Sprintf("%sUnitTestSetups[%d]", prefix, i), &problems))
+	}
+
+	for i, unitTest := range file.UnitTestTeardowns {
+		config.UnitTestTeardowns = append(config.UnitTestTeardowns, unitTest.unitTest(dir, fmt.Sprintf("%sUnitTestTeardowns[%d]", prefix, i), &problems))
+	}
+
+	for name, profile := range file.Profiles {
+		if config.Profiles == nil {
+			config.Profiles = map[string]FixtureConfig{}
+		}
+
+		profileConfig, err := profile.config(dir, fmt.Sprintf("%sProfiles[%s].", prefix, name))
+		problems.add(err)
+		config.Profiles[name] = profileConfig
+	}
+
+	return config, problems.err()
+}
+
+func (file dbFile) db(dir string, field string, problems *problems) DB {
+	db := DB{
+		Connection: DBConn{
+			Driver:   file.Connection.Driver,
+			String:   file.Connection.String,
+			Host:     file.Connection.Host,
+			Port:     file.Connection.Port,
+			User:     file.Connection.User,
+			Password: file.Connection.Password,
+			Database: file.Connection.Database,
+			Params:   file.Connection.Params,
+		},
+	}
+
+	db.Connection.WaitTimeout = parseDuration(file.Connection.WaitTimeout, field+".Connection.WaitTimeout", problems)
+
+	for i, script := range file.Scripts {
+		db.Scripts = append(db.Scripts, script.script(dir, fmt.Sprintf("%s.Scripts[%d]", field, i), problems))
+	}
+
+	return db
+}
+
+func (file scriptFile) script(dir string, field string, problems *problems) Script {
+	set := 0
+	for _, isSet := range []bool{file.SQL != "", file.Path != "", file.CleanTables, file.CreateDatabase != "", file.DropDatabase != ""} {
+		if isSet {
+			set++
+		}
+	}
+
+	if set != 1 {
+		problems.addf(field, "%s must set one of sql, path, clean_tables, create_database or drop_database", field)
+	}
+
+	if len(file.Exclude) > 0 && !file.CleanTables {
+		problems.addf(field, "%s can only set exclude with clean_tables", field)
+	}
+
+	if file.Template && file.SQL == "" && file.Path == "" {
+		problems.addf(field, "%s can only set template with sql or path", field)
+	}
+
+	var script Script
+
+	switch {
+	case file.Path != "":
+		script = NewScriptPath(resolvePath(dir, file.Path))
+	case file.CleanTables:
+		script = NewCleanTablesScript(file.Exclude...)
+	case file.CreateDatabase != "":
+		script = NewCreateDatabaseScript(file.CreateDatabase)
+	case file.DropDatabase != "":
+		script = NewDropDatabaseScript(file.DropDatabase)
+	default:
+		script = NewScript(file.SQL)
+	}
+
+	script.Template = file.Template
+	return script
+}
+
+func (file appFile) app(dir string, field string, problems *problems) App {
+	app := App{
+		Name:              file.Name,
+		Root:              resolvePath(dir, file.Root),
+		InProcess:         file.InProcess,
+		DependsOn:         file.DependsOn,
+		Port:              file.Port,
+		BuildArguments:    file.BuildArguments,
+		RunArguments:      file.RunArguments,
+		Env:               file.Env,
+		WaitForOutputLine: file.WaitForOutputLine,
+		BaseURL:           file.BaseURL,
+		Race:              file.Race,
+		Cover:             file.Cover,
+		CoverPackages:     file.CoverPackages,
+		CoverProfile:      resolvePath(dir, file.CoverProfile),
+	}
+
+	app.WaitTimeout = parseDuration(file.WaitTimeout, field+".WaitTimeout", problems)
+	app.ShutdownTimeout = parseDuration(file.ShutdownTimeout, field+".ShutdownTimeout", problems)
+
+	return app
+}
+
+func (file unitTestFile) unitTest(dir string, field string, problems *problems) UnitTest {
+	var unitTest UnitTest
+
+	for i, db := range file.DatabaseRoutines {
+		unitTest.DatabaseRoutines = append(unitTest.DatabaseRoutines, db.db(dir, fmt.Sprintf("%s.DatabaseRoutines[%d]", field, i), problems))
+	}
+
+	return unitTest
+}
+
+// parseDuration parses a duration such as "10s" from a config file, which is optional.
+func parseDuration(value string, field string, problems *problems) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		problems.addf(field, "%s \"%s\" is not a valid duration, e.g. \"10s\"", field, value)
+	}
+
+	return duration
+}
+
+// resolvePath makes a relative path in a config file absolute, relative to the file's directory.
+func resolvePath(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,74 @@
+package baloon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const raceSeparator = "=================="
+
+// raceReports returns any race detector reports found in the output of an App built with -race.
+func raceReports(output string) []string {
+	var reports []string
+	var report []string
+
+	inReport := false
+	lines := strings.Split(output, "\n")
+
+	for i, line := range lines {
+		if !inReport {
+			if line == "WARNING: DATA RACE" {
+				inReport = true
+				report = nil
+				if i > 0 && lines[i-1] == raceSeparator {
+					report = append(report, raceSeparator)
+				}
+				report = append(report, line)
+			}
+			continue
+		}
+
+		report = append(report, line)
+		if line == raceSeparator {
+			reports = append(reports, strings.Join(report, "\n"))
+			inReport = false
+		}
+	}
+
+	// output ended part way through a report
+	if inReport {
+		reports = append(reports, strings.Join(report, "\n"))
+	}
+
+	return reports
+}
+
+// writeCoverProfile converts the binary coverage data written by an App built with -cover
+// into a standard coverprofile, using "go tool covdata".
+func writeCoverProfile(appRoot string, coverDir string, profile string) error {
+	files, err := os.ReadDir(coverDir)
+	if err != nil {
+		return fmt.Errorf("Error reading coverage data directory: %s", err.Error())
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("No coverage data was written by program. Make sure it exits normally when sent an interrupt signal.")
+	}
+
+	if !filepath.IsAbs(profile) {
+		profile = filepath.Join(appRoot, profile)
+	}
+
+	cmd := exec.Command("go", "tool", "covdata", "textfmt", "-i="+coverDir, "-o="+profile)
+	cmd.Dir = appRoot
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Error converting coverage data: %s\n%s", err.Error(), output)
+	}
+
+	return nil
+}
@@ -1,10 +1,16 @@
 package baloon
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"path"
 	"path/filepath"
+	"text/template"
+	"time"
 )
 
 // DB represents a series of database scripts to run against a database given its Connection.
@@ -21,9 +27,104 @@ type DB struct {
 	Scripts []Script
 }
 
-// Run will run the database setup
-func (dbSetup DB) run(appRoot string) error {
-	db, err := sql.Open(dbSetup.Connection.Driver, dbSetup.Connection.String)
+// open opens a connection to the database.
+func (conn DBConn) open() (*sql.DB, error) {
+	dsn, err := conn.DSN()
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.Open(conn.Driver, dsn)
+}
+
+// connect opens a connection to the database, waiting up to 'WaitTimeout' for the database
+// server to accept connections.
+func (conn DBConn) connect() (*sql.DB, error) {
+	db, err := conn.open()
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.wait(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// wait pings the database until it responds, backing off between attempts. If it doesn't
+// respond within 'WaitTimeout', the error from the last attempt is returned.
+func (conn DBConn) wait(db *sql.DB) error {
+	if conn.WaitTimeout <= 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(conn.WaitTimeout)
+	delay := 50 * time.Millisecond
+
+	var lastErr error
+	for {
+		// give the last attempt a little time, rather than none
+		attemptDeadline := deadline
+		if minimum := time.Now().Add(100 * time.Millisecond); attemptDeadline.Before(minimum) {
+			attemptDeadline = minimum
+		}
+
+		ctx, cancel := context.WithDeadline(context.Background(), attemptDeadline)
+		err := db.PingContext(ctx)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		// keep the connection error, rather than the ping running out of time
+		if lastErr == nil || !errors.Is(err, context.DeadlineExceeded) {
+			lastErr = err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("Database wasn't ready after %s: %w", conn.WaitTimeout, lastErr)
+		}
+
+		// try one last time at the deadline
+		if delay > remaining {
+			delay = remaining
+		}
+
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > time.Second {
+			delay = time.Second
+		}
+	}
+}
+
+// expand returns the database setup with any templates in its connection string executed.
+func (dbSetup DB) expand(funcs template.FuncMap) (DB, error) {
+	connection, err := expandTemplate(dbSetup.Connection.String, funcs, nil)
+	if err != nil {
+		return dbSetup, err
+	}
+
+	database, err := expandTemplate(dbSetup.Connection.Database, funcs, nil)
+	if err != nil {
+		return dbSetup, err
+	}
+
+	dbSetup.Connection.String = connection
+	dbSetup.Connection.Database = database
+	return dbSetup, nil
+}
+
+// Run will run the database setup, using funcs in templated Scripts, and masking
+// secrets in the scripts quoted in errors with redactor.
+func (dbSetup DB) run(appRoot string, funcs template.FuncMap, redactor *redactor) error {
+	db, err := dbSetup.Connection.connect()
 	if err != nil {
 		return fmt.Errorf("Error connecting to database: %s", err.Error())
 	}
@@ -31,33 +132,124 @@ func (dbSetup DB) run(appRoot string) error {
 
 	var scripts []Script
 
-	if dbSetup.Script.Type == ScriptTypeLiteral || dbSetup.Script.Type == ScriptTypePath {
+	if dbSetup.Script.Type != 0 {
 		scripts = append(scripts, dbSetup.Script)
 	}
 	scripts = append(scripts, dbSetup.Scripts...)
 
 	for _, script := range scripts {
 		if script.Type == ScriptTypeLiteral {
-			_, err = db.Exec(script.Command)
+			command := script.Command
+			if script.Template {
+				command, err = executeScriptTemplate("script", command, funcs)
+			}
+			if err == nil {
+				_, err = db.Exec(command)
+			}
 			if err != nil {
-				return fmt.Errorf("Error running script \"%s\": %s", truncate(script.Command, 40, "..."), err.Error())
+				// mask before truncating, so a secret cut in two is still masked
+				return fmt.Errorf("Error running script \"%s\": %s", truncate(redactor.string(script.Command), 40, "..."), err.Error())
 			}
 		} else if script.Type == ScriptTypePath {
-			globPath := filepath.Join(appRoot, script.Command)
-			files, err := filepath.Glob(globPath)
+			files, err := filepath.Glob(scriptPath(appRoot, script.Command))
 			if err != nil {
 				return fmt.Errorf("Error getting files from path \"%s\": %s", script.Command, err.Error())
 			}
 
 			for _, file := range files {
 				data, err := ioutil.ReadFile(file)
-				_, err = db.Exec(string(data))
+				if err != nil {
+					return fmt.Errorf("Error reading script \"%s\": %s", file, err.Error())
+				}
+
+				command := string(data)
+				if script.Template {
+					command, err = executeScriptTemplate(filepath.Base(file), command, funcs)
+				}
+				if err == nil {
+					_, err = db.Exec(command)
+				}
+				if err != nil {
+					return fmt.Errorf("Error executing script \"%s\": %s", file, err.Error())
+				}
+			}
+		} else if script.Type == ScriptTypeFS {
+			if script.FS == nil {
+				return fmt.Errorf("Error getting files from pattern \"%s\": FS has not been set", script.Command)
+			}
+
+			files, err := fs.Glob(script.FS, script.Command)
+			if err != nil {
+				return fmt.Errorf("Error getting files from pattern \"%s\": %s", script.Command, err.Error())
+			}
+
+			for _, file := range files {
+				data, err := fs.ReadFile(script.FS, file)
+				if err != nil {
+					return fmt.Errorf("Error reading script \"%s\": %s", file, err.Error())
+				}
+
+				command := string(data)
+				if script.Template {
+					command, err = executeScriptTemplate(path.Base(file), command, funcs)
+				}
+				if err == nil {
+					_, err = db.Exec(command)
+				}
 				if err != nil {
 					return fmt.Errorf("Error executing script \"%s\": %s", file, err.Error())
 				}
 			}
+		} else if script.Type == ScriptTypeCleanTables {
+			err = cleanTables(db, dbSetup.Connection.Driver, script.Exclude)
+			if err != nil {
+				return fmt.Errorf("Error cleaning tables: %s", err.Error())
+			}
+		} else if script.Type == ScriptTypeCreateDatabase {
+			err = runAdminScript(db, dbSetup.Connection.Driver, script, appRoot)
+			if err != nil {
+				return fmt.Errorf("Error creating database \"%s\": %s", script.Command, err.Error())
+			}
+		} else if script.Type == ScriptTypeDropDatabase {
+			err = runAdminScript(db, dbSetup.Connection.Driver, script, appRoot)
+			if err != nil {
+				return fmt.Errorf("Error dropping database \"%s\": %s", script.Command, err.Error())
+			}
 		}
 	}
 
 	return nil
 }
+
+// scriptPath returns the glob pattern for a path Script, which is relative to appRoot
+// unless it's absolute.
+func scriptPath(appRoot string, pattern string) string {
+	if filepath.IsAbs(pattern) {
+		return pattern
+	}
+
+	return filepath.Join(appRoot, pattern)
+}
+
+// withScriptFS returns copies of the database setups, with fsys as the FS of any
+// ScriptTypeFS scripts that weren't given one.
+func withScriptFS(dbSetups []DB, fsys fs.FS) []DB {
+	copies := make([]DB, len(dbSetups))
+
+	for i, dbSetup := range dbSetups {
+		if dbSetup.Script.Type == ScriptTypeFS && dbSetup.Script.FS == nil {
+			dbSetup.Script.FS = fsys
+		}
+
+		dbSetup.Scripts = append([]Script{}, dbSetup.Scripts...)
+		for j := range dbSetup.Scripts {
+			if dbSetup.Scripts[j].Type == ScriptTypeFS && dbSetup.Scripts[j].FS == nil {
+				dbSetup.Scripts[j].FS = fsys
+			}
+		}
+
+		copies[i] = dbSetup
+	}
+
+	return copies
+}
@@ -0,0 +1,71 @@
+package baloon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dialect implements database specific commands for a family of database/sql drivers.
+type dialect interface {
+	// name returns a human readable name for the database.
+	name() string
+
+	// quote returns name quoted as an identifier, e.g. a table or database name.
+	quote(name string) string
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) name() string {
+	return "Postgres"
+}
+
+func (postgresDialect) quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) name() string {
+	return "MySQL"
+}
+
+func (mysqlDialect) quote(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) name() string {
+	return "SQLite"
+}
+
+func (sqliteDialect) quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+type sqlserverDialect struct{}
+
+func (sqlserverDialect) name() string {
+	return "SQL Server"
+}
+
+func (sqlserverDialect) quote(name string) string {
+	return "[" + strings.Replace(name, "]", "]]", -1) + "]"
+}
+
+// dialectFor returns the dialect for a database/sql driver name.
+func dialectFor(driver string) (dialect, error) {
+	switch driver {
+	case "postgres", "pgx", "pgx/v4", "pgx/v5", "cloudsqlpostgres":
+		return postgresDialect{}, nil
+	case "mysql":
+		return mysqlDialect{}, nil
+	case "sqlite3", "sqlite":
+		return sqliteDialect{}, nil
+	case "sqlserver", "mssql":
+		return sqlserverDialect{}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported database driver \"%s\"", driver)
+}
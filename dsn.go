@@ -0,0 +1,323 @@
+package baloon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dsnDialect is a dialect that can build connection strings from the structured DBConn
+// settings, and change the database in an existing connection string.
+type dsnDialect interface {
+	dialect
+
+	// dsn returns the connection string for conn's Host, Port, User, Password,
+	// Database and Params.
+	dsn(conn DBConn) string
+
+	// withDatabase returns dsn connecting to database instead. An empty database
+	// connects to the server's default database.
+	withDatabase(dsn string, database string) (string, error)
+}
+
+// structured returns whether any of the structured connection settings are set.
+func (conn DBConn) structured() bool {
+	return conn.Host != "" || conn.Port != 0 || conn.User != "" || conn.Password != "" ||
+		conn.Database != "" || conn.Params != ""
+}
+
+// DSN returns the connection string passed to sql.Open. This is 'String' if it's set,
+// otherwise it's built from the structured settings, e.g. 'Host' and 'Database'.
+func (conn DBConn) DSN() (string, error) {
+	if conn.String != "" || !conn.structured() {
+		return conn.String, nil
+	}
+
+	dsnDialect, err := dsnDialectFor(conn.Driver)
+	if err != nil {
+		return "", err
+	}
+
+	return dsnDialect.dsn(conn), nil
+}
+
+// WithDatabase returns a copy of the connection that connects to a different database on
+// the same server, e.g. to connect to the server's "postgres" database to create the
+// database the tests use. An empty name connects to the server's default database.
+//
+// If 'String' is set, the database in it is replaced, for Postgres and SQL Server URLs
+// and key=value strings, MySQL DSNs and SQLite file paths. An error is returned if it
+// can't be replaced.
+func (conn DBConn) WithDatabase(name string) (DBConn, error) {
+	if conn.String == "" {
+		conn.Database = name
+		return conn, nil
+	}
+
+	dsnDialect, err := dsnDialectFor(conn.Driver)
+	if err != nil {
+		return DBConn{}, err
+	}
+
+	dsn, err := dsnDialect.withDatabase(conn.String, name)
+	if err != nil {
+		return DBConn{}, fmt.Errorf("Error changing the database in %s connection string: %w", dsnDialect.name(), err)
+	}
+
+	conn.String = dsn
+	return conn, nil
+}
+
+func dsnDialectFor(driver string) (dsnDialect, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	dsnDialect, ok := d.(dsnDialect)
+	if !ok {
+		return nil, fmt.Errorf("Connection strings can't be built for %s databases", d.name())
+	}
+
+	return dsnDialect, nil
+}
+
+// hostPort joins a host and port, either of which may be empty.
+func hostPort(host string, port int) string {
+	if port == 0 {
+		return host
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// userInfo returns the user and password for a URL, or nil if there's no user.
+func userInfo(conn DBConn) *url.Userinfo {
+	if conn.User == "" {
+		return nil
+	}
+
+	if conn.Password == "" {
+		return url.User(conn.User)
+	}
+
+	return url.UserPassword(conn.User, conn.Password)
+}
+
+// setKeyValue replaces a setting in a key=value connection string, e.g. dbname=northwind,
+// adding it if it's not there, or removing it if value is empty.
+func setKeyValue(dsn string, key string, value string, separator string, quote func(string) string) string {
+	pattern := regexp.MustCompile(`(?i)(^|` + separator + `)\s*` + key + `\s*=\s*('[^']*'|[^` + separator + `]*)`)
+
+	if value == "" {
+		return strings.Trim(pattern.ReplaceAllString(dsn, ""), separator+" ")
+	}
+
+	setting := key + "=" + quote(value)
+
+	if pattern.MatchString(dsn) {
+		return pattern.ReplaceAllStringFunc(dsn, func(match string) string {
+			if strings.HasPrefix(match, separator) {
+				return separator + setting
+			}
+			return setting
+		})
+	}
+
+	if dsn == "" || strings.HasSuffix(dsn, separator) {
+		return dsn + setting
+	}
+
+	return dsn + separator + setting
+}
+
+func (postgresDialect) dsn(conn DBConn) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     userInfo(conn),
+		Host:     hostPort(conn.Host, conn.Port),
+		Path:     "/" + conn.Database,
+		RawQuery: conn.Params,
+	}
+
+	return dsn.String()
+}
+
+func (postgresDialect) withDatabase(dsn string, database string) (string, error) {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// leave out the URL, which can have a password in it
+			return "", errors.Unwrap(err)
+		}
+
+		u.Path = "/" + database
+		u.RawPath = ""
+		return u.String(), nil
+	}
+
+	// key=value connection strings, e.g. "host=localhost dbname=northwind"
+	return setKeyValue(dsn, "dbname", database, " ", quotePostgresValue), nil
+}
+
+// quotePostgresValue quotes a value in a Postgres key=value connection string if needed.
+func quotePostgresValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+func (mysqlDialect) dsn(conn DBConn) string {
+	var dsn strings.Builder
+
+	if conn.User != "" {
+		dsn.WriteString(conn.User)
+		if conn.Password != "" {
+			dsn.WriteString(":" + conn.Password)
+		}
+		dsn.WriteString("@")
+	}
+
+	if conn.Host != "" || conn.Port != 0 {
+		dsn.WriteString("tcp(" + hostPort(conn.Host, conn.Port) + ")")
+	}
+
+	dsn.WriteString("/" + conn.Database)
+
+	if conn.Params != "" {
+		dsn.WriteString("?" + conn.Params)
+	}
+
+	return dsn.String()
+}
+
+var (
+	// the address in a MySQL DSN and the "/" before the database, after the user and
+	// password, e.g. @tcp(localhost:3306)/ or @unix(/tmp/mysql.sock)/
+	mysqlAddressPattern = regexp.MustCompile(`(^|@)\w+\([^)]*\)/`)
+
+	// a MySQL DSN without an address, e.g. root@/northwind or /northwind
+	mysqlNoAddressPattern = regexp.MustCompile(`(^|@)\w*/`)
+)
+
+// mysqlAddress returns the index of the "@" after the user and password in a MySQL DSN,
+// or -1 if there's no user, and the index of the "/" before the database, or -1 if there
+// isn't one. Passwords and parameters can contain "@" and "/" too, so the address is found
+// first, e.g. root:p@ss@tcp(localhost:3306)/northwind?x=a@b.
+func mysqlAddress(dsn string) (at int, slash int) {
+	match := mysqlAddressPattern.FindStringIndex(dsn)
+	if match == nil {
+		match = mysqlNoAddressPattern.FindStringIndex(dsn)
+	}
+
+	if match == nil {
+		return -1, -1
+	}
+
+	if dsn[match[0]] != '@' {
+		return -1, match[1] - 1
+	}
+
+	return match[0], match[1] - 1
+}
+
+func (mysqlDialect) withDatabase(dsn string, database string) (string, error) {
+	// e.g. user:password@tcp(localhost:3306)/northwind?parseTime=true
+	_, slash := mysqlAddress(dsn)
+	if slash == -1 {
+		return "", fmt.Errorf("Missing \"/\" before database name")
+	}
+
+	end := len(dsn)
+	if question := strings.Index(dsn[slash:], "?"); question != -1 {
+		end = slash + question
+	}
+
+	return dsn[:slash+1] + database + dsn[end:], nil
+}
+
+func (sqliteDialect) dsn(conn DBConn) string {
+	if conn.Params == "" {
+		return conn.Database
+	}
+
+	return "file:" + conn.Database + "?" + conn.Params
+}
+
+func (sqliteDialect) withDatabase(dsn string, database string) (string, error) {
+	// keep the "file:" prefix and parameters, e.g. file:northwind.db?_foreign_keys=on
+	prefix := ""
+	if strings.HasPrefix(dsn, "file:") {
+		prefix = "file:"
+		dsn = dsn[len(prefix):]
+	}
+
+	params := ""
+	if question := strings.Index(dsn, "?"); question != -1 {
+		params = dsn[question:]
+	}
+
+	return prefix + database + params, nil
+}
+
+func (sqlserverDialect) dsn(conn DBConn) string {
+	query, _ := url.ParseQuery(conn.Params)
+	if conn.Database != "" {
+		query.Set("database", conn.Database)
+	}
+
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		User:     userInfo(conn),
+		Host:     hostPort(conn.Host, conn.Port),
+		RawQuery: query.Encode(),
+	}
+
+	return dsn.String()
+}
+
+func (sqlserverDialect) withDatabase(dsn string, database string) (string, error) {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// leave out the URL, which can have a password in it
+			return "", errors.Unwrap(err)
+		}
+
+		query := u.Query()
+		if database == "" {
+			query.Del("database")
+		} else {
+			query.Set("database", database)
+		}
+
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	// ADO connection strings, e.g. "server=localhost;user id=sa;database=northwind", where
+	// "initial catalog" is another name for "database"
+	if initialCatalogPattern.MatchString(dsn) {
+		return setKeyValue(dsn, "initial catalog", database, ";", quoteADOValue), nil
+	}
+
+	return setKeyValue(dsn, "database", database, ";", quoteADOValue), nil
+}
+
+// initialCatalogPattern matches the "initial catalog" setting in an ADO connection string.
+var initialCatalogPattern = regexp.MustCompile(`(?i)(^|;)\s*initial catalog\s*=`)
+
+// quoteADOValue quotes a value in an ADO connection string if needed.
+func quoteADOValue(value string) string {
+	if !strings.ContainsAny(value, `;'"`) {
+		return value
+	}
+
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
@@ -0,0 +1,144 @@
+package baloon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// These errors are returned when the Fixture's methods are called in the wrong order, or
+// an App doesn't start in time. Use errors.Is to check for them, as they're wrapped in
+// errors with more detail.
+var (
+	// ErrAlreadySetup is returned when Setup is called more than once.
+	ErrAlreadySetup = errors.New("Setup() has already been called")
+
+	// ErrNotSetup is returned when Teardown, or a method used while running
+	// tests, is called before Setup.
+	ErrNotSetup = errors.New("Setup() has not been called")
+
+	// ErrAlreadyTornDown is returned when Teardown is called more than once,
+	// or a method used while running tests is called after Teardown.
+	ErrAlreadyTornDown = errors.New("Fixture has already been teared down")
+
+	// ErrStartupTimeout is returned when an App isn't ready to accept HTTP
+	// requests within its WaitTimeout.
+	ErrStartupTimeout = errors.New("Timeout waiting for program to start")
+)
+
+// sentinelError is an error with its own message, that matches one of the sentinel
+// errors above with errors.Is.
+type sentinelError struct {
+	message  string
+	sentinel error
+}
+
+func newSentinelError(sentinel error, format string, args ...interface{}) error {
+	return &sentinelError{
+		message:  fmt.Sprintf(format, args...),
+		sentinel: sentinel,
+	}
+}
+
+func (err *sentinelError) Error() string {
+	return err.message
+}
+
+func (err *sentinelError) Unwrap() error {
+	return err.sentinel
+}
+
+// ConfigError is returned by NewFixture when a setting in the FixtureConfig is invalid.
+type ConfigError struct {
+	// Field is the setting that is invalid, e.g. "AppSetup.WaitForOutputLine"
+	// or "Snapshots[0]".
+	Field string
+
+	// Message describes what is wrong with the setting.
+	Message string
+}
+
+func newConfigError(field string, format string, args ...interface{}) error {
+	return &ConfigError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (err *ConfigError) Error() string {
+	return err.Message
+}
+
+// StepError is an error from one of the steps of Teardown or Close, such as shutting
+// down an App or running a Database Teardown.
+type StepError struct {
+	// Step is a short description of the step that failed, e.g. "stop app".
+	Step string
+
+	// Err is the error the step failed with.
+	Err error
+}
+
+func (err *StepError) Error() string {
+	return err.Err.Error()
+}
+
+// Unwrap returns the error the step failed with, for use with errors.Is and errors.As.
+func (err *StepError) Unwrap() error {
+	return err.Err
+}
+
+// MultiError is returned by Teardown and Close, which carry on with the rest of their steps
+// when one fails, collecting each failure as a StepError. It's also returned by NewFixture,
+// with a ConfigError for each problem with the FixtureConfig.
+type MultiError struct {
+	// Errors is the list of failures, in the order they happened.
+	Errors []error
+}
+
+func (err *MultiError) Error() string {
+	if len(err.Errors) == 1 {
+		return err.Errors[0].Error()
+	}
+
+	messages := make([]string, len(err.Errors))
+	for i, e := range err.Errors {
+		messages[i] = e.Error()
+	}
+
+	return fmt.Sprintf("%d errors:\n%s", len(err.Errors), strings.Join(messages, "\n"))
+}
+
+// Unwrap returns the list of failures, for use with errors.Is and errors.As.
+func (err *MultiError) Unwrap() []error {
+	return err.Errors
+}
+
+// steps collects the failures of the steps of Teardown or Close.
+type steps struct {
+	errors []error
+}
+
+// fail records that a step failed, if err isn't nil.
+func (steps *steps) fail(step string, err error) {
+	if err == nil {
+		return
+	}
+
+	// keep failures from nested steps flat
+	if multiErr, ok := err.(*MultiError); ok {
+		steps.errors = append(steps.errors, multiErr.Errors...)
+		return
+	}
+
+	steps.errors = append(steps.errors, &StepError{Step: step, Err: err})
+}
+
+// err returns a MultiError with the failures, or nil if no steps failed.
+func (steps *steps) err() error {
+	if len(steps.errors) == 0 {
+		return nil
+	}
+
+	return &MultiError{Errors: steps.errors}
+}
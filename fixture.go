@@ -1,267 +1,589 @@
 package baloon
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"os/exec"
-	"path"
-	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
-	"time"
+	"text/template"
 )
 
 // Fixture represents a test fixture. You usually have one per test suite.
+// It is safe to use from multiple goroutines, e.g. from tests that call t.Parallel(),
+// though only tests using Acquire get their own copies of databases.
 type Fixture struct {
+	mu *sync.Mutex
+
 	config            FixtureConfig
 	unitTestSetups    []UnitTest
 	unitTestTeardowns []UnitTest
 
-	appPath                  string
-	appProcess               *exec.Cmd
+	apps                     []*appRunner
+	snapshots                []*snapshotter
+	trackers                 []*changeTracker
+	checkers                 []*pollutionChecker
+	pollution                []string
+	pool                     *pool
+	journal                  *journal
+	redactor                 *redactor
+	stopSignals              func()
 	alreadyAttemptedSetup    bool
 	alreadyAttemptedTeardown bool
 }
 
 // Setup runs the fixture setup. Call this only once before running all your tests, usually in func MainTest()
-func (fixture *Fixture) Setup() error {
-	if fixture.alreadyAttemptedSetup {
-		return fmt.Errorf("Setup() has already been called. Only run this function once for the test suite.")
-	}
+// Until Teardown or Close, the Fixture is cleaned up with Close if the test binary is sent SIGINT or SIGTERM.
+// Anything left behind by an earlier test run that was killed, e.g. by go test's -timeout, is cleaned up first.
+func (fixture *Fixture) Setup() (err error) {
+	fixture.mu.Lock()
+	defer fixture.mu.Unlock()
 
-	fixture.alreadyAttemptedSetup = true
+	defer func() {
+		err = fixture.redactor.err(err)
+	}()
 
-	for i, dbSetup := range fixture.config.DatabaseSetups {
-		err := dbSetup.run(fixture.config.AppRoot)
-		if err != nil {
-			return fmt.Errorf("Error running Database Setup at index %d: %s", i, err.Error())
-		}
+	if fixture.alreadyAttemptedSetup {
+		return newSentinelError(ErrAlreadySetup, "Setup() has already been called. Only run this function once for the test suite.")
 	}
 
-	appRoot := fixture.config.AppRoot
-	appName := path.Base(appRoot)
-
-	// build app
-	appSetup := fixture.config.AppSetup
-
-	buildArgs := appSetup.BuildArguments
+	fixture.alreadyAttemptedSetup = true
 
-	containsOutputArg := false
-	containsBuildArg := false
+	// clean up after earlier test runs that were killed before they could clean up
+	replayJournals(fixture.config.AppRoot, fixture.journalConnections())
 
-	for i, arg := range buildArgs {
-		if arg == "-o" {
-			containsOutputArg = true
-			fixture.appPath = buildArgs[i+1]
-		}
-		if arg == "build" {
-			containsBuildArg = true
-		}
+	journal, err := newJournal(fixture.config.AppRoot)
+	if err != nil {
+		return fmt.Errorf("Error creating cleanup journal: %w", err)
 	}
+	fixture.journal = journal
 
-	if fixture.appPath == "" {
-		fixture.appPath = "./" + appName + "_" + randomCharacters(8)
+	for _, app := range fixture.apps {
+		app.journal = journal
 	}
 
-	if !containsBuildArg {
-		buildArgs = append([]string{"build"}, buildArgs...)
+	for _, snapshot := range fixture.snapshots {
+		snapshot.journal = journal
 	}
 
-	if !containsOutputArg {
-		buildArgs = append(buildArgs, "-o", fixture.appPath)
+	if fixture.pool != nil {
+		for _, snapshotter := range fixture.pool.databases {
+			snapshotter.journal = journal
+		}
 	}
 
-	cmd := exec.Command("go", buildArgs...)
-	cmd.Dir = appRoot
+	fixture.handleSignals(journal)
 
-	err := cmd.Run()
-	if err != nil {
-		return fmt.Errorf("Error building program: %s", err.Error())
-	}
+	funcs := fixture.templateFuncs()
 
-	// run app
-	appProcess := exec.Command(fixture.appPath, appSetup.RunArguments...)
-	appProcess.Dir = appRoot
+	for i, dbSetup := range fixture.config.DatabaseSetups {
+		err := dbSetup.run(fixture.config.AppRoot, funcs, fixture.redactor)
+		if err != nil {
+			return fmt.Errorf("Error running Database Setup at index %d: %w", i, err)
+		}
+	}
 
-	fixture.appProcess = appProcess
+	for _, snapshot := range fixture.snapshots {
+		err := snapshot.create()
+		if err != nil {
+			return err
+		}
+	}
 
-	outReader, err := appProcess.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("Error getting stdout pipe from running program: %s", err.Error())
+	for i, tracker := range fixture.trackers {
+		err := tracker.install()
+		if err != nil {
+			return fmt.Errorf("Error setting up Change Tracking at index %d: %w", i, err)
+		}
 	}
-	defer outReader.Close()
 
-	errReader, err := appProcess.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("Error getting stderr pipe from running program: %s", err.Error())
+	if fixture.pool != nil {
+		err := fixture.pool.create()
+		if err != nil {
+			return err
+		}
 	}
-	defer errReader.Close()
 
-	outScanner := bufio.NewScanner(outReader)
-	errScanner := bufio.NewScanner(errReader)
+	// build and run apps
+	for _, app := range fixture.apps {
+		err := app.build()
+		if err != nil {
+			return err
+		}
 
-	err = appProcess.Start()
-	if err != nil {
-		return fmt.Errorf("Error running program under test: %s", err.Error())
+		err = app.allocate(funcs)
+		if err != nil {
+			return err
+		}
 	}
 
-	outDone := make(chan struct{})
-	go func() {
-		for outScanner.Scan() {
-			if outScanner.Text() == fixture.config.AppSetup.WaitForOutputLine {
-				close(outDone)
-				break
-			}
+	for _, app := range fixture.apps {
+		err := app.start(funcs, AppOptions{})
+		if err != nil {
+			return err
 		}
-	}()
+	}
 
-	errDone := make(chan struct{})
-	go func() {
-		for errScanner.Scan() {
-			if errScanner.Text() == fixture.config.AppSetup.WaitForOutputLine {
-				close(errDone)
-				break
-			}
+	if fixture.pool != nil {
+		err := fixture.pool.startApps(fixture.apps, funcs)
+		if err != nil {
+			return err
 		}
-	}()
+	}
 
-	select {
-	case <-outDone:
-		return nil
-	case <-errDone:
-		return nil
-	case <-time.After(fixture.config.AppSetup.WaitTimeout):
-		return fmt.Errorf("Timeout waiting for program to start. Was looking for output line \"%s\".",
-			fixture.config.AppSetup.WaitForOutputLine)
+	for i, checker := range fixture.checkers {
+		baseline, err := checker.fingerprint()
+		if err != nil {
+			return fmt.Errorf("Error running Pollution Check at index %d: %w", i, err)
+		}
+		checker.baseline = baseline
 	}
+
+	return nil
 }
 
 // Teardown runs the fixture teardown routines. Call this only once after running all your tests,
 // usually in func MainTest() after the call to m.Run()
 func (fixture *Fixture) Teardown() error {
+	fixture.mu.Lock()
+	defer fixture.mu.Unlock()
+
 	if !fixture.alreadyAttemptedSetup {
-		return fmt.Errorf("Please run Setup() first before calling Teardown()")
+		return newSentinelError(ErrNotSetup, "Please run Setup() first before calling Teardown()")
 	}
 
 	if fixture.alreadyAttemptedTeardown {
-		return fmt.Errorf("Teardown() has already been called. Only run this function once for the test suite.")
+		return newSentinelError(ErrAlreadyTornDown, "Teardown() has already been called. Only run this function once for the test suite.")
 	}
 
 	fixture.alreadyAttemptedTeardown = true
 
-	// shut down app
-	if fixture.appProcess != nil && fixture.appProcess.Process != nil {
-		err := fixture.appProcess.Process.Kill()
+	// carry on when a step fails, so as much as possible is cleaned up
+	var steps steps
+
+	// shut down apps in reverse order, starting with those for parallel tests
+	steps.fail("stop app", fixture.pool.stopApps())
+
+	for i := len(fixture.apps) - 1; i >= 0; i-- {
+		app := fixture.apps[i]
+
+		err := app.stop()
+		if err != nil {
+			steps.fail("stop app", fmt.Errorf("Error shutting down program: %w", err))
+		}
+
+		// delete program file
+		err = app.removeBinary()
 		if err != nil {
-			return fmt.Errorf("Error shutting down program: %s", err.Error())
+			steps.fail("remove binary", fmt.Errorf("Error trying to delete complile binary: %w", err))
 		}
 	}
 
-	// delete program file
-	fullAppPath := filepath.Join(fixture.config.AppRoot, fixture.appPath)
+	for _, snapshot := range fixture.snapshots {
+		steps.fail("drop snapshot", snapshot.drop())
+	}
 
-	_, err := os.Stat(fullAppPath)
-	if err == nil {
-		err := os.Remove(fullAppPath)
+	for i, tracker := range fixture.trackers {
+		err := tracker.uninstall()
 		if err != nil {
-			return fmt.Errorf("Error trying to delete complile binary: %s", err.Error())
+			steps.fail("remove change tracking", fmt.Errorf("Error removing Change Tracking at index %d: %w", i, err))
 		}
 	}
 
+	steps.fail("drop parallel databases", fixture.pool.drop())
+
 	// run database teardown
 	for i, dbSetup := range fixture.config.DatabaseTeardowns {
-		err := dbSetup.run(fixture.config.AppRoot)
+		err := dbSetup.run(fixture.config.AppRoot, fixture.templateFuncs(), fixture.redactor)
 		if err != nil {
-			return fmt.Errorf("Error running Database Teardown at index %d: %s", i, err.Error())
+			steps.fail("database teardown", fmt.Errorf("Error running Database Teardown at index %d: %w", i, err))
 		}
 	}
 
+	// write coverage profiles
+	for _, app := range fixture.apps {
+		if app.coverDir != "" {
+			steps.fail("write coverage profile", writeCoverProfile(app.root, app.coverDir, app.app.CoverProfile))
+			os.RemoveAll(app.coverDir)
+		}
+	}
+
+	// fail on any data races
+	for _, app := range append(append([]*appRunner{}, fixture.apps...), fixture.pool.instances()...) {
+		if races := raceReports(app.output.String()); len(races) > 0 {
+			steps.fail("check data races", fmt.Errorf("Race detector found %d data race(s) in program%s:\n%s",
+				len(races), app.describe(), strings.Join(races, "\n")))
+		}
+	}
+
+	if len(fixture.pollution) > 0 {
+		steps.fail("check pollution", fmt.Errorf("Tests left the database different from after Setup:\n%s", strings.Join(fixture.pollution, "\n")))
+	}
+
+	fixture.stopHandlingSignals()
+
+	// keep the journal if anything wasn't cleaned up, for the next Setup
+	if len(steps.errors) == 0 {
+		fixture.journal.remove()
+	}
+
+	return fixture.redactor.err(steps.err())
+}
+
+// BaseURL returns the root URL your App is serving HTTP requests on, either
+// App.BaseURL or the URL of the test server when running InProcess. When using
+// multiple Apps, this is the URL of the first App.
+func (fixture *Fixture) BaseURL() string {
+	app := fixture.primaryApp()
+	if app == nil {
+		return ""
+	}
+
+	return app.baseURL()
+}
+
+// AppURL returns the root URL the App with the given name is serving HTTP requests on.
+func (fixture *Fixture) AppURL(name string) string {
+	app, err := fixture.appNamed(name)
+	if err != nil {
+		return ""
+	}
+
+	return app.baseURL()
+}
+
+// AppOutput returns everything the App with the given name has written to stdout
+// and stderr, across any restarts. Use an empty name for the first App.
+func (fixture *Fixture) AppOutput(name string) string {
+	app, err := fixture.findApp(name)
+	if err != nil {
+		return ""
+	}
+
+	return fixture.redactor.string(app.output.String())
+}
+
+// StopApp shuts down the App with the given name during the test suite, so it
+// can be started again with StartApp. Use an empty name for the first App.
+func (fixture *Fixture) StopApp(name string) error {
+	fixture.mu.Lock()
+	defer fixture.mu.Unlock()
+
+	app, err := fixture.runningApp(name, "StopApp")
+	if err != nil {
+		return err
+	}
+
+	err = app.stop()
+	if err != nil {
+		return fixture.redactor.err(fmt.Errorf("Error shutting down program: %w", err))
+	}
+
+	return nil
+}
+
+// StartApp starts an App that has been stopped with StopApp, reusing the already
+// built executable, and waits for it to be ready again. 'options' can change the
+// RunArguments and environment variables for this run of the App.
+func (fixture *Fixture) StartApp(options AppOptions) error {
+	fixture.mu.Lock()
+	defer fixture.mu.Unlock()
+
+	app, err := fixture.runningApp(options.Name, "StartApp")
+	if err != nil {
+		return err
+	}
+
+	if app.running() {
+		return fmt.Errorf("App%s is already running. Call StopApp() first.", app.describe())
+	}
+
+	return fixture.redactor.err(app.start(fixture.templateFuncs(), options))
+}
+
+// RestartApp stops and starts an App, reusing the already built executable, and
+// waits for it to be ready again. 'options' can change the RunArguments and
+// environment variables for this run of the App.
+func (fixture *Fixture) RestartApp(options AppOptions) error {
+	err := fixture.StopApp(options.Name)
+	if err != nil {
+		return err
+	}
+
+	return fixture.StartApp(options)
+}
+
+// runningApp returns an App to stop or start, checking the fixture is set up.
+// The Fixture must be locked.
+func (fixture *Fixture) runningApp(name string, caller string) (*appRunner, error) {
+	err := fixture.checkSetup(caller)
+	if err != nil {
+		return nil, err
+	}
+
+	return fixture.findApp(name)
+}
+
+// checkSetup checks the Fixture has been set up, and not yet torn down.
+// The Fixture must be locked.
+func (fixture *Fixture) checkSetup(caller string) error {
+	if !fixture.alreadyAttemptedSetup {
+		return newSentinelError(ErrNotSetup, "Please run Setup() first before calling %s()", caller)
+	}
+
+	if fixture.alreadyAttemptedTeardown {
+		return ErrAlreadyTornDown
+	}
+
 	return nil
 }
 
 // AddUnitTestSetup adds a UnitTest setup routine to the test Fixture
 func (fixture *Fixture) AddUnitTestSetup(setup UnitTest) {
+	fixture.mu.Lock()
+	defer fixture.mu.Unlock()
+
+	fixture.redactor.addConnections(setup)
+	setup.DatabaseRoutines = withScriptFS(setup.DatabaseRoutines, fixture.config.ScriptFS)
 	fixture.unitTestSetups = append(fixture.unitTestSetups, setup)
 }
 
 // UnitTestSetup will run all UnitTest setup routines. This is run at the start of each individual test,
-// e.g. func TestSomething(t *testing.T), within your test suite.
-func (fixture *Fixture) UnitTestSetup(t *testing.T) {
-	if !fixture.alreadyAttemptedSetup {
-		t.Fatalf("Please run Setup() first before calling TestSetup()")
+// e.g. func TestSomething(t *testing.T), within your test suite. Benchmarks and fuzz tests can pass
+// their *testing.B or *testing.F.
+func (fixture *Fixture) UnitTestSetup(tb testing.TB) {
+	fixture.mu.Lock()
+	unitTestSetups, err := fixture.beginUnitTest()
+	fixture.mu.Unlock()
+	if err != nil {
+		tb.Fatal(fixture.redactor.err(err))
 	}
 
-	if fixture.alreadyAttemptedTeardown {
-		t.Fatalf("Fixture has already been teared down")
+	fixture.runUnitTests(tb, unitTestSetups, fixture.templateFuncs(), "TestSetup")
+}
+
+// beginUnitTest restores snapshots and then takes fingerprints for pollution checks,
+// returning the UnitTest setup routines to run. The Fixture must be locked.
+func (fixture *Fixture) beginUnitTest() ([]UnitTest, error) {
+	err := fixture.checkSetup("TestSetup")
+	if err != nil {
+		return nil, err
 	}
 
-	for i, testSetup := range fixture.unitTestSetups {
-		for dbIndex, dbSetup := range testSetup.DatabaseRoutines {
-			err := dbSetup.run(fixture.config.AppRoot)
-			if err != nil {
-				t.Fatalf("Error running Database Setup at index %d for TestSetup at index %d: %s",
-					dbIndex, i, err.Error())
-			}
+	for _, snapshot := range fixture.snapshots {
+		err := snapshot.restore()
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if testSetup.Func != nil {
-			testSetup.Func(t)
+	// after restoring, so pollution undone by the restore isn't blamed on this test
+	for i, checker := range fixture.checkers {
+		before, err := checker.fingerprint()
+		if err != nil {
+			return nil, fmt.Errorf("Error running Pollution Check at index %d: %w", i, err)
 		}
+		checker.before = before
 	}
+
+	return fixture.unitTestSetups, nil
+}
+
+// Begin runs all UnitTest setup routines, like UnitTestSetup, and registers UnitTestTeardown
+// to run when the test or benchmark and its subtests complete, using tb.Cleanup. Call it at the
+// start of each test instead of calling UnitTestSetup and UnitTestTeardown yourself.
+func (fixture *Fixture) Begin(tb testing.TB) {
+	// registered first, so the teardown runs even if a setup routine fails
+	tb.Cleanup(func() {
+		fixture.UnitTestTeardown(tb)
+	})
+
+	fixture.UnitTestSetup(tb)
 }
 
 // AddUnitTestTeardown adds a UnitTest teardown routine to the test Fixture
 func (fixture *Fixture) AddUnitTestTeardown(teardown UnitTest) {
+	fixture.mu.Lock()
+	defer fixture.mu.Unlock()
+
+	fixture.redactor.addConnections(teardown)
+	teardown.DatabaseRoutines = withScriptFS(teardown.DatabaseRoutines, fixture.config.ScriptFS)
 	fixture.unitTestTeardowns = append(fixture.unitTestTeardowns, teardown)
 }
 
 // UnitTestTeardown will run all UnitTest teardown routines. This is run at the end of each individual test,
-// e.g. func TestSomething(t *testing.T), within your test suite.
-func (fixture *Fixture) UnitTestTeardown(t *testing.T) {
-	if !fixture.alreadyAttemptedSetup {
-		t.Fatalf("Please run Setup() first before calling TestTeardown()")
+// e.g. func TestSomething(t *testing.T), within your test suite. Benchmarks and fuzz tests can pass
+// their *testing.B or *testing.F.
+func (fixture *Fixture) UnitTestTeardown(tb testing.TB) {
+	fixture.mu.Lock()
+	err := fixture.checkSetup("TestTeardown")
+	unitTestTeardowns := fixture.unitTestTeardowns
+	fixture.mu.Unlock()
+	if err != nil {
+		tb.Fatal(fixture.redactor.err(err))
 	}
 
-	if fixture.alreadyAttemptedTeardown {
-		t.Fatalf("Fixture has already been teared down")
+	fixture.runUnitTests(tb, unitTestTeardowns, fixture.templateFuncs(), "TestTeardown")
+
+	fixture.mu.Lock()
+	defer fixture.mu.Unlock()
+
+	for i, tracker := range fixture.trackers {
+		err := tracker.restore()
+		if err != nil {
+			tb.Fatalf("Error restoring changes for Change Tracking at index %d: %s", i, fixture.redactor.string(err.Error()))
+		}
+	}
+
+	for i, checker := range fixture.checkers {
+		after, err := checker.fingerprint()
+		if err != nil {
+			tb.Fatalf("Error running Pollution Check at index %d: %s", i, fixture.redactor.string(err.Error()))
+		}
+
+		pollution := checker.pollution(after)
+		if len(pollution) > 0 {
+			report := fmt.Sprintf("%s: %s", tb.Name(), strings.Join(pollution, ", "))
+			fixture.pollution = append(fixture.pollution, report)
+			tb.Logf("Test left the database different from after Setup: %s", strings.Join(pollution, ", "))
+		}
 	}
+}
 
-	for i, testTeardown := range fixture.unitTestTeardowns {
-		for dbIndex, dbSetup := range testTeardown.DatabaseRoutines {
-			err := dbSetup.run(fixture.config.AppRoot)
+// runUnitTests runs the database routines and funcs of each UnitTest, using funcs
+// for any templates in the database connection strings.
+func (fixture *Fixture) runUnitTests(tb testing.TB, unitTests []UnitTest, funcs template.FuncMap, name string) {
+	for i, unitTest := range unitTests {
+		for dbIndex, dbSetup := range unitTest.DatabaseRoutines {
+			dbSetup, err := dbSetup.expand(funcs)
+			if err == nil {
+				err = dbSetup.run(fixture.config.AppRoot, funcs, fixture.redactor)
+			}
 			if err != nil {
-				t.Fatalf("Error running Database Setup at index %d for TestTeardown at index %d: %s",
-					dbIndex, i, err.Error())
+				tb.Fatalf("Error running Database Setup at index %d for %s at index %d: %s",
+					dbIndex, name, i, fixture.redactor.string(err.Error()))
 			}
 		}
 
-		if testTeardown.Func != nil {
-			testTeardown.Func(t)
+		if unitTest.Func != nil {
+			unitTest.Func(tb)
 		}
 	}
 }
 
-// Close will attempt to free up any resources created by the Fixture.
-// Make sure to call this before any log.Fatal() or os.Exit() calls.
-func (fixture *Fixture) Close() {
+// Acquire leases a set of resources from the Parallel pool to the test, waiting for one to
+// become free, and runs the UnitTest setup routines using the test's own copies of the
+// Parallel Databases. When the test and its subtests complete, the UnitTest teardown
+// routines are run, and the Lease is restored and returned to the pool, using tb.Cleanup.
+// Use this instead of UnitTestSetup and UnitTestTeardown in tests that call t.Parallel().
+func (fixture *Fixture) Acquire(tb testing.TB) *Lease {
+	fixture.mu.Lock()
+	err := fixture.checkSetup("Acquire")
+	pool := fixture.pool
+	unitTestSetups := fixture.unitTestSetups
+	fixture.mu.Unlock()
+	if err != nil {
+		tb.Fatal(fixture.redactor.err(err))
+	}
+
+	if pool == nil {
+		tb.Fatalf("FixtureConfig.Parallel has not been set")
+	}
+
+	lease := pool.acquire()
+	funcs := lease.templateFuncs(fixture.templateFuncs())
+
+	tb.Cleanup(func() {
+		defer func() {
+			err := pool.release(lease)
+			if err != nil {
+				tb.Errorf("Error restoring databases for Lease: %s", fixture.redactor.string(err.Error()))
+			}
+		}()
+
+		fixture.mu.Lock()
+		unitTestTeardowns := fixture.unitTestTeardowns
+		fixture.mu.Unlock()
+
+		fixture.runUnitTests(tb, unitTestTeardowns, funcs, "TestTeardown")
+	})
+
+	// restoring failed when it was last released
+	if lease.dirty {
+		err := lease.reset()
+		if err != nil {
+			tb.Fatalf("Error restoring databases for Lease: %s", fixture.redactor.string(err.Error()))
+		}
+	}
+
+	fixture.runUnitTests(tb, unitTestSetups, funcs, "TestSetup")
+	return lease
+}
+
+// Close will attempt to free up any resources created by the Fixture, running Teardown if it
+// hasn't already run. Make sure to call this before any log.Fatal() or os.Exit() calls. Close
+// carries on when freeing a resource fails, returning a *MultiError with each failure, which
+// includes Teardown's failures, and Teardown panicking.
+func (fixture *Fixture) Close() (err error) {
+	// not created with NewFixture, so there's nothing to free
+	if fixture.mu == nil {
+		return nil
+	}
+
+	var steps steps
+
 	defer func() {
 		// in case teardown panics
-		recover()
+		if r := recover(); r != nil {
+			steps.fail("teardown", fmt.Errorf("Teardown panicked: %v", r))
+		}
+
+		fixture.mu.Lock()
+		defer fixture.mu.Unlock()
+
+		for _, snapshot := range fixture.snapshots {
+			steps.fail("drop snapshot", snapshot.drop())
+		}
 
-		// kill process if it's running
-		if fixture.appProcess != nil && fixture.appProcess.Process != nil {
-			fixture.appProcess.Process.Kill()
+		for _, tracker := range fixture.trackers {
+			steps.fail("remove change tracking", tracker.uninstall())
 		}
 
-		// delete executable if it exists
-		_, err := os.Stat(fixture.appPath)
-		if err == nil {
-			os.Remove(fixture.appPath)
+		for _, app := range fixture.pool.instances() {
+			steps.fail("stop app", app.kill())
 		}
+
+		steps.fail("drop parallel databases", fixture.pool.drop())
+
+		for _, app := range fixture.apps {
+			// kill process if it's running
+			steps.fail("stop app", app.kill())
+
+			// delete executable if it exists
+			steps.fail("remove binary", app.removeBinary())
+
+			if app.coverDir != "" {
+				steps.fail("remove coverage data", os.RemoveAll(app.coverDir))
+			}
+		}
+
+		fixture.stopHandlingSignals()
+
+		if len(steps.errors) == 0 {
+			fixture.journal.remove()
+		}
+
+		err = fixture.redactor.err(steps.err())
 	}()
 
 	// attempt to run teardown if not already
-	if !fixture.alreadyAttemptedTeardown {
-		fixture.Teardown()
+	fixture.mu.Lock()
+	needsTeardown := fixture.alreadyAttemptedSetup && !fixture.alreadyAttemptedTeardown
+	fixture.mu.Unlock()
+
+	if needsTeardown {
+		steps.fail("teardown", fixture.Teardown())
 	}
+
+	return nil
 }
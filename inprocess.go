@@ -0,0 +1,106 @@
+package baloon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"time"
+)
+
+// startInProcess serves the App from within the test binary, either by serving its
+// Handler with an httptest.Server, or by calling its Server func on a free port.
+func (runner *appRunner) startInProcess() error {
+	if runner.app.Handler != nil {
+		server := httptest.NewUnstartedServer(runner.app.Handler)
+
+		// keep the same port when restarting
+		if port := runner.listenPort(); port != 0 {
+			listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+			if err != nil {
+				return fmt.Errorf("Error running program under test%s: %s", runner.describe(), err.Error())
+			}
+			server.Listener.Close()
+			server.Listener = listener
+		}
+
+		server.Start()
+
+		runner.server = server
+		runner.setAddress(server.Listener.Addr().(*net.TCPAddr).Port, server.URL)
+		return nil
+	}
+
+	addr := fmt.Sprintf("127.0.0.1:%d", runner.listenPort())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serverErr := make(chan error, 1)
+	exited := make(chan struct{})
+
+	go func() {
+		serverErr <- runner.app.Server(ctx, addr)
+		close(exited)
+	}()
+
+	runner.cancel = cancel
+	runner.exited = exited
+
+	timeout := time.After(runner.app.WaitTimeout)
+
+	for {
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+
+		select {
+		case err := <-serverErr:
+			if err == nil {
+				return fmt.Errorf("Program under test stopped before it started listening on %s", addr)
+			}
+			return fmt.Errorf("Error running program under test: %s", err.Error())
+		case <-timeout:
+			return newSentinelError(ErrStartupTimeout, "Timeout waiting for program to start. Was waiting for it to listen on %s.", addr)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// stopInProcess shuts down an App being served from within the test binary.
+func (runner *appRunner) stopInProcess(timeout time.Duration) error {
+	if runner.server != nil {
+		runner.server.Close()
+		runner.server = nil
+		return nil
+	}
+
+	if runner.cancel == nil {
+		return nil
+	}
+
+	runner.cancel()
+	runner.cancel = nil
+
+	if timeout <= 0 {
+		return nil
+	}
+
+	select {
+	case <-runner.exited:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("Timeout waiting for program to stop")
+	}
+}
+
+// freePort returns a local TCP port that's free to listen on.
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,249 @@
+package baloon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// journal records the processes, files and databases a Fixture creates, so that if the
+// test binary dies before they can be cleaned up (e.g. go test's -timeout), the next
+// Setup for the same AppRoot can clean them up instead. Journals are stored in
+// os.TempDir()/baloon/<hash of AppRoot>/<pid>-<random>.journal.
+type journal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// journalEntry is a line in a journal, recording either an App process, a file, a
+// temporary directory, or a database to delete. Conn is the connection string with any
+// password masked, which is matched against the Fixture's connections when replaying.
+type journalEntry struct {
+	PID      int    `json:"pid,omitempty"`
+	Binary   string `json:"binary,omitempty"`
+	File     string `json:"file,omitempty"`
+	Dir      string `json:"dir,omitempty"`
+	Driver   string `json:"driver,omitempty"`
+	Conn     string `json:"conn,omitempty"`
+	Database string `json:"database,omitempty"`
+}
+
+// journalDir returns the directory journals for an AppRoot are stored in.
+func journalDir(appRoot string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(appRoot))
+	return filepath.Join(os.TempDir(), "baloon", strconv.FormatUint(hash.Sum64(), 16))
+}
+
+// newJournal creates a journal for this test binary.
+func newJournal(appRoot string) (*journal, error) {
+	dir := journalDir(appRoot)
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%d-%s.journal", os.Getpid(), randomCharacters(8)))
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal{path: path}, file.Close()
+}
+
+// record appends an entry to the journal. Errors are ignored, as the journal is only
+// needed if cleaning up fails.
+func (journal *journal) record(entry journalEntry) {
+	if journal == nil {
+		return
+	}
+
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	file, err := os.OpenFile(journal.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	file.Write(append(data, '\n'))
+	file.Sync()
+}
+
+// process records an App process, and the executable it's running.
+func (journal *journal) process(pid int, binary string) {
+	journal.record(journalEntry{PID: pid, Binary: binary})
+}
+
+// file records a file to delete, such as the App executable.
+func (journal *journal) file(path string) {
+	journal.record(journalEntry{File: path})
+}
+
+// tempDir records a coverage data directory created with os.MkdirTemp, to delete along
+// with its contents.
+func (journal *journal) tempDir(path string) {
+	journal.record(journalEntry{Dir: path})
+}
+
+// database records a database to drop, such as a snapshot or copy of a database.
+func (journal *journal) database(conn DBConn, database string) {
+	dsn, err := redactedDSN(conn)
+	if err != nil {
+		return
+	}
+
+	journal.record(journalEntry{Driver: conn.Driver, Conn: dsn, Database: database})
+}
+
+// remove deletes the journal, once everything in it has been cleaned up.
+func (journal *journal) remove() error {
+	if journal == nil {
+		return nil
+	}
+
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	err := os.Remove(journal.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// replay kills the processes, and deletes the files and databases, recorded in the journal,
+// then deletes the journal. Databases are dropped using the matching connection in conns.
+func (journal *journal) replay(conns []DBConn) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	replayJournal(journal.path, conns)
+	os.Remove(journal.path)
+}
+
+// replayJournals cleans up after earlier test runs for an AppRoot that died before they
+// could clean up, i.e. any journals whose test binary is no longer running. Databases are
+// dropped using the matching connection in conns. Cleaning up is best effort, so errors
+// are ignored.
+func replayJournals(appRoot string, conns []DBConn) {
+	paths, err := filepath.Glob(filepath.Join(journalDir(appRoot), "*.journal"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		pid, err := strconv.Atoi(strings.SplitN(filepath.Base(path), "-", 2)[0])
+		if err != nil || processAlive(pid) {
+			continue
+		}
+
+		replayJournal(path, conns)
+		os.Remove(path)
+	}
+}
+
+// replayJournal kills the processes, and deletes the files and databases, in a journal.
+// Journals are kept in a shared directory, so only regular files, and temporary directories
+// baloon created, are deleted.
+func replayJournal(path string, conns []DBConn) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry journalEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	// kill processes before deleting the files and databases they're using
+	for _, entry := range entries {
+		if entry.PID != 0 {
+			killLeftoverProcess(entry.PID, entry.Binary)
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.File != "" {
+			info, err := os.Lstat(entry.File)
+			if err == nil && info.Mode().IsRegular() {
+				os.Remove(entry.File)
+			}
+		}
+
+		if entry.Dir != "" && filepath.Dir(entry.Dir) == filepath.Clean(os.TempDir()) &&
+			strings.HasPrefix(filepath.Base(entry.Dir), coverDirPattern) {
+			os.RemoveAll(entry.Dir)
+		}
+
+		if entry.Database != "" {
+			conn, ok := journalConnection(entry, conns)
+			if !ok {
+				continue
+			}
+
+			d, err := dialectFor(conn.Driver)
+			if err != nil {
+				continue
+			}
+
+			if snapshotDialect, ok := d.(snapshotDialect); ok {
+				snapshotDialect.dropTemplate(conn, entry.Database)
+			}
+		}
+	}
+}
+
+// journalConnection returns the connection in conns that a journal entry's database
+// was created with, as the journal doesn't store passwords.
+func journalConnection(entry journalEntry, conns []DBConn) (DBConn, bool) {
+	for _, conn := range conns {
+		dsn, err := redactedDSN(conn)
+		if err == nil && conn.Driver == entry.Driver && dsn == entry.Conn {
+			return conn, true
+		}
+	}
+
+	return DBConn{}, false
+}
+
+// journalConnections returns the connections the Fixture creates databases with, which
+// are used to drop databases when replaying journals.
+func (fixture *Fixture) journalConnections() []DBConn {
+	var conns []DBConn
+
+	for _, snapshotter := range fixture.snapshots {
+		conns = append(conns, snapshotter.snapshot.Connection)
+	}
+
+	if fixture.pool != nil {
+		for _, snapshotter := range fixture.pool.databases {
+			conns = append(conns, snapshotter.snapshot.Connection)
+		}
+	}
+
+	return conns
+}
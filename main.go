@@ -2,14 +2,19 @@
 package baloon
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
 
-// These consts represent the 2 types of database scripts we can use, either literal or file path
+// These consts represent the types of database scripts we can use, either literal, file path, or built-in routines
 const (
 	// ScriptTypeLiteral specifies literal database command text
 	ScriptTypeLiteral = 1
@@ -17,6 +22,22 @@ const (
 	// ScriptTypePath specifies a glob file pattern for
 	// database commands stored in files
 	ScriptTypePath = 2
+
+	// ScriptTypeCleanTables deletes all rows from every table in
+	// the database, except those listed in the Script's 'Exclude'
+	ScriptTypeCleanTables = 3
+
+	// ScriptTypeCreateDatabase creates the database named by the
+	// Script's 'Command', if it doesn't already exist
+	ScriptTypeCreateDatabase = 4
+
+	// ScriptTypeDropDatabase deletes the database named by the Script's
+	// 'Command' if it exists, disconnecting anything connected to it first
+	ScriptTypeDropDatabase = 5
+
+	// ScriptTypeFS specifies a glob file pattern for database
+	// commands stored in files in the Script's 'FS'
+	ScriptTypeFS = 6
 )
 
 // DBConn represents a database connection including the driver and connection string.
@@ -27,8 +48,38 @@ type DBConn struct {
 	Driver string
 
 	// String is the database connection string, passed as the
-	// second argument to sql.Open
+	// second argument to sql.Open. If it's not set, the connection string is
+	// built from 'Host', 'Port', 'User', 'Password', 'Database' and 'Params'
+	// for Postgres, MySQL, SQLite and SQL Server drivers.
 	String string
+
+	// Host is the database server's host name, when not using 'String'.
+	Host string
+
+	// Port is the database server's port, when not using 'String'. Defaults
+	// to the driver's default port.
+	Port int
+
+	// User is the user to connect as, when not using 'String'.
+	User string
+
+	// Password is the user's password, when not using 'String'.
+	Password string
+
+	// Database is the name of the database to connect to, when not using
+	// 'String'. For SQLite this is the path of the database file. Can use
+	// {{database "name"}} like 'String'.
+	Database string
+
+	// Params are any other driver settings, in URL query form, e.g.
+	// "sslmode=disable&connect_timeout=5", when not using 'String'.
+	Params string
+
+	// WaitTimeout is how long baloon should wait for the database server to
+	// accept connections before running scripts, retrying with a backoff, e.g.
+	// when the database starts alongside the tests in CI. If not set, baloon
+	// doesn't wait.
+	WaitTimeout time.Duration
 }
 
 // Script represents a database script run either as a setup or teardown routine.
@@ -38,9 +89,37 @@ type Script struct {
 	// Type is the Script type to use.
 	Type int
 
-	// Command is either a literal database command, or a file
-	// glob pattern, depending on the 'Type'.
+	// Command is either a literal database command, a file glob
+	// pattern, or a database name, depending on the 'Type'.
 	Command string
+
+	// Exclude is a list of table names to leave alone when cleaning
+	// tables with ScriptTypeCleanTables. Names can include the schema.
+	Exclude []string
+
+	// FS is the file system to read ScriptTypeFS script files from.
+	// Defaults to FixtureConfig.ScriptFS.
+	FS fs.FS
+
+	// Template executes literal scripts and script files as text/templates
+	// before running them. See AsTemplate.
+	Template bool
+}
+
+// AsTemplate returns a copy of the Script where the literal script, or the contents of each
+// script file, is executed as a text/template before it's run. Templates can use the fixture's
+// {{var "name"}}, {{port "name"}}, {{url "name"}} and {{database "name"}}, plus:
+//
+//	{{now}}                the current time.Time, e.g. {{now.Format "2006-01-02"}}
+//	{{uuid}}               a random version 4 UUID
+//	{{random 8}}           a random string of 8 lowercase letters and digits
+//	{{env "NAME"}}         an environment variable, with an optional default: {{env "NAME" "default"}}
+//	{{bcrypt "password"}}  a bcrypt hash of the password
+//
+// Values are inserted as they are, so quote them as the SQL needs, e.g. '{{uuid}}'.
+func (script Script) AsTemplate() Script {
+	script.Template = true
+	return script
 }
 
 // NewScript returns a Script that represents a literal database command to run.
@@ -52,6 +131,7 @@ func NewScript(command string) Script {
 }
 
 // NewScriptPath returns a Script that represents a glob path to a script files or files to run.
+// The path is relative to AppRoot, unless it's absolute.
 func NewScriptPath(path string) Script {
 	return Script{
 		Type:    ScriptTypePath,
@@ -59,16 +139,83 @@ func NewScriptPath(path string) Script {
 	}
 }
 
+// NewScriptFS returns a Script that represents a glob pattern to a script file or files in fsys,
+// such as an embed.FS, run in the same order as NewScriptPath. Patterns use forward slashes and
+// are relative to the root of fsys. If fsys is nil, FixtureConfig.ScriptFS is used.
+func NewScriptFS(fsys fs.FS, pattern string) Script {
+	return Script{
+		Type:    ScriptTypeFS,
+		Command: pattern,
+		FS:      fsys,
+	}
+}
+
+// NewCreateDatabaseScript returns a Script that creates a database, if it doesn't already exist.
+// The Script's connection must be to a different database on the same server, e.g. "postgres"
+// for Postgres, or "master" for SQL Server. For SQLite, name is the path of the database file,
+// relative to AppRoot unless it's absolute. Supports Postgres, MySQL, SQLite and SQL Server.
+func NewCreateDatabaseScript(name string) Script {
+	return Script{
+		Type:    ScriptTypeCreateDatabase,
+		Command: name,
+	}
+}
+
+// NewDropDatabaseScript returns a Script that deletes a database if it exists, disconnecting
+// anything still connected to it first, such as your App. The Script's connection and name
+// are as for NewCreateDatabaseScript.
+func NewDropDatabaseScript(name string) Script {
+	return Script{
+		Type:    ScriptTypeDropDatabase,
+		Command: name,
+	}
+}
+
+// NewCleanTablesScript returns a Script that deletes all rows from every table in the database,
+// except for the tables listed in exclude, and resets identity/auto-increment counters.
+// Tables are found automatically, and cleaned in an order that respects foreign keys.
+// Supports Postgres, MySQL, SQLite and SQL Server.
+func NewCleanTablesScript(exclude ...string) Script {
+	return Script{
+		Type:    ScriptTypeCleanTables,
+		Exclude: exclude,
+	}
+}
+
 // App represents settings and arguments for your Go HTTP API executable.
 type App struct {
+	// Name identifies the App when using multiple Apps, so that other Apps can
+	// depend on it or reference its port and URL.
+	Name string
+
+	// Root is the directory of this App's main package, relative to AppRoot.
+	// Defaults to AppRoot.
+	Root string
+
+	// DependsOn is a list of names of other Apps that must be started before
+	// this one. Apps are shut down in the reverse order.
+	DependsOn []string
+
+	// Port is the TCP port your App should listen on. If not set, baloon will
+	// allocate a free port. Pass it to your App using the {{.Port}} template
+	// in 'RunArguments'.
+	Port int
+
 	// BuildArguments is a list of build arguments to include when baloon
 	// tries to buld your App executable. They are run via "go build yourArgsHere..."
 	BuildArguments []string
 
 	// RunArguments is a list of command line arguments to
-	// include when your Go executable is run.
+	// include when your Go executable is run. Arguments can use text/template
+	// actions, with {{.Port}} and {{.URL}} for this App, {{port "name"}} and
+	// {{url "name"}} for other Apps, and {{var "name"}} for fixture Variables.
 	RunArguments []string
 
+	// Env is a list of additional environment variables, in the form
+	// "KEY=value", to set when your Go executable is run. Values can use the
+	// same templates as 'RunArguments'.
+	Env []string
+
 	// WaitForOutputLine specifies a line of text that baloon should wait
 	// to appear in either stdout or stderr in order to signal that the App is
 	// ready to start excepting HTTP requests.
@@ -77,6 +224,63 @@ type App struct {
 	// WaitTimeout is how long baloon should wait for the
 	// 'WaitForOutputLine' to appear.
 	WaitTimeout time.Duration
+
+	// BaseURL is the root URL your App executable serves HTTP requests on,
+	// returned by Fixture.BaseURL(). Defaults to "http://localhost:{{.Port}}".
+	// Not needed when running InProcess.
+	BaseURL string
+
+	// InProcess serves your App from within the test binary using 'Handler' or
+	// 'Server', rather than building and running your App executable. This is
+	// much faster for local iteration, and can be toggled with a test flag.
+	InProcess bool
+
+	// Handler is your App's http.Handler, which is served using an
+	// httptest.Server when running InProcess.
+	Handler http.Handler
+
+	// Server is a func that starts your App's HTTP server listening on addr,
+	// blocking until ctx is cancelled. It is used instead of 'Handler' when
+	// running InProcess, and baloon waits for addr to accept connections
+	// (up to 'WaitTimeout') before running tests.
+	Server func(ctx context.Context, addr string) error
+
+	// ShutdownTimeout is how long baloon should wait for your App to exit
+	// after sending it an interrupt signal during Teardown, before killing it.
+	ShutdownTimeout time.Duration
+
+	// Race builds your App executable with the race detector enabled. Any data
+	// races reported by your App will cause Teardown to return an error
+	// containing the race detector report.
+	Race bool
+
+	// Cover builds your App executable with coverage instrumentation (Go 1.20+),
+	// so you can see which parts of your App the end-to-end tests exercised.
+	// Your App must exit normally when sent an interrupt signal for coverage
+	// data to be written.
+	Cover bool
+
+	// CoverPackages is an optional list of package patterns to collect coverage
+	// for, passed to "go build -coverpkg". Defaults to your App's main package.
+	CoverPackages []string
+
+	// CoverProfile is the path of the coverage profile to write during Teardown
+	// when 'Cover' is set, in the standard format read by "go tool cover".
+	// Relative paths are relative to AppRoot.
+	CoverProfile string
+}
+
+// AppOptions are settings used when starting or restarting an App during the test suite.
+type AppOptions struct {
+	// Name is the name of the App to start. Defaults to the first App.
+	Name string
+
+	// RunArguments, if not nil, are used instead of the App's 'RunArguments'.
+	RunArguments []string
+
+	// Env is a list of environment variables, in the form "KEY=value", to set
+	// in addition to the App's 'Env'.
+	Env []string
 }
 
 // FixtureConfig is a configuration object for your test Fixture.
@@ -89,57 +293,248 @@ type FixtureConfig struct {
 	// before the test suite is run.
 	DatabaseSetups []DB
 
+	// Snapshots is a list of databases to copy after the DatabaseSetups have
+	// run, which are then restored from the copy at the start of each unit test
+	// in UnitTestSetup.
+	Snapshots []Snapshot
+
+	// ChangeTracking is a list of databases where baloon tracks which tables
+	// each unit test changes, and restores just those tables to how they were
+	// after Setup in UnitTestTeardown.
+	ChangeTracking []ChangeTracking
+
+	// PollutionChecks is a list of databases to check at the end of each
+	// UnitTestTeardown, to find tests that leave the database different from
+	// how it was after Setup. This is a diagnostic mode, as it's slow.
+	PollutionChecks []PollutionCheck
+
+	// Parallel configures a pool of isolated resources, such as copies of
+	// databases, for tests that call t.Parallel(). Tests lease resources
+	// from the pool with Fixture.Acquire.
+	Parallel Parallel
+
 	// AppSetup specifies configuration settings for your Go app executable.
 	AppSetup App
 
+	// Apps specifies configuration settings for multiple Go app executables,
+	// for systems made up of several services. Use this instead of 'AppSetup'.
+	// Apps are started in the order given, after any Apps they depend on.
+	Apps []App
+
+	// Variables are values that can be used in App 'RunArguments' templates
+	// via {{var "name"}}.
+	Variables map[string]string
+
+	// Secrets are values, such as API keys, that are masked in the errors the
+	// Fixture returns and anything it logs or reports, including App output.
+	// Database passwords are always masked where they're written in a
+	// connection string, but secrets are masked wherever they appear.
+	Secrets []string
+
+	// ScriptFS is the file system that scripts created with NewScriptFS read
+	// from when they're not given one, e.g. an embed.FS of seed data.
+	ScriptFS fs.FS
+
+	// UnitTestSetups is a list of routines to run at the start of each unit
+	// test, as if added with Fixture.AddUnitTestSetup.
+	UnitTestSetups []UnitTest
+
+	// UnitTestTeardowns is a list of routines to run at the end of each unit
+	// test, as if added with Fixture.AddUnitTestTeardown.
+	UnitTestTeardowns []UnitTest
+
 	// DatabaseTeardowns is a list of one or more database teardown
 	// commands to run after the test suite has run.
 	DatabaseTeardowns []DB
+
+	// Profiles are named sets of settings, such as "ci", that are merged over
+	// the rest of the config when chosen. Only settings that are set in the
+	// profile are used: structs such as 'AppSetup' are merged field by field,
+	// lists of structs such as 'DatabaseSetups' are merged item by item, and
+	// 'Variables' are merged by name. Lists of strings, such as
+	// 'RunArguments', are replaced, except 'Secrets', which are added to.
+	Profiles map[string]FixtureConfig
+
+	// Profile is the name of the profile in 'Profiles' to use. Defaults to the
+	// -baloon.profile test flag if it's registered with RegisterProfileFlag,
+	// or the BALOON_PROFILE environment variable.
+	Profile string
 }
 
 // UnitTest represents database commands and a func to run at the beginning or end of each unit test.
 type UnitTest struct {
 	// DatabaseRoutines is a list of one or more database setup commands to
-	// run before each unit, or at the end of each unit test.
+	// run before each unit, or at the end of each unit test. Connection strings
+	// can use {{database "name"}} for the name of a Parallel Database, which is
+	// the test's own copy when using Fixture.Acquire.
 	DatabaseRoutines []DB
 
-	// Func is a function to run before each unit test is run.
-	Func func(t *testing.T)
+	// Func is a function to run before each unit test is run. It is passed
+	// the *testing.T, *testing.B or *testing.F of the unit test.
+	Func func(tb testing.TB)
 }
 
-// NewFixture returns a Fixture, but also verifies that everything has been set up correctly.
+// NewFixture returns a Fixture, but also verifies that everything has been set up correctly,
+// e.g. that database drivers have been imported and script paths match some files. Every
+// problem found is returned at once, as a MultiError of ConfigErrors.
 func NewFixture(config FixtureConfig) (Fixture, error) {
-	var fixture Fixture
+	fixture := Fixture{mu: &sync.Mutex{}, redactor: &redactor{}}
+
+	config, err := config.withProfile()
+	if err != nil {
+		return fixture, err
+	}
+
+	fixture.redactor.add(config.Secrets...)
+	fixture.redactor.addConnections(config)
+
+	var problems problems
 
-	// check AppRoot is set
 	if len(config.AppRoot) == 0 {
-		return fixture, fmt.Errorf("AppRoot is missing")
+		// check AppRoot is set
+		problems.addf("AppRoot", "AppRoot is missing")
+	} else if !filepath.IsAbs(config.AppRoot) {
+		// must be absolute path
+		problems.addf("AppRoot", "Please use an absolute path, or try using something like filepath.Abs(\"./../\")")
+	} else if _, err := os.Stat(config.AppRoot); os.IsNotExist(err) {
+		// check AppRoot exists
+		problems.addf("AppRoot", "AppRoot directory does not exist")
+	} else if err != nil {
+		problems.addf("AppRoot", "Error determining if AppRoot exists: %s", err.Error())
 	}
 
-	// must be absolute path
-	if !filepath.IsAbs(config.AppRoot) {
-		return fixture, fmt.Errorf("Please use an absolute path, or try using something like filepath.Abs(\"./../\")")
+	// scripts created with NewScriptFS without a file system read from ScriptFS
+	config.DatabaseSetups = withScriptFS(config.DatabaseSetups, config.ScriptFS)
+	config.DatabaseTeardowns = withScriptFS(config.DatabaseTeardowns, config.ScriptFS)
+
+	config.UnitTestSetups = append([]UnitTest{}, config.UnitTestSetups...)
+	for i := range config.UnitTestSetups {
+		config.UnitTestSetups[i].DatabaseRoutines = withScriptFS(config.UnitTestSetups[i].DatabaseRoutines, config.ScriptFS)
 	}
 
-	// check AppRoot exists
-	_, err := os.Stat(config.AppRoot)
-	if os.IsNotExist(err) {
-		return fixture, fmt.Errorf("AppRoot directory does not exist")
-	} else if err != nil {
-		return fixture, fmt.Errorf("Error determining if AppRoot exists: %s", err.Error())
+	config.UnitTestTeardowns = append([]UnitTest{}, config.UnitTestTeardowns...)
+	for i := range config.UnitTestTeardowns {
+		config.UnitTestTeardowns[i].DatabaseRoutines = withScriptFS(config.UnitTestTeardowns[i].DatabaseRoutines, config.ScriptFS)
 	}
 
-	// check wait for output set
-	if config.AppSetup.WaitForOutputLine == "" {
-		return fixture, fmt.Errorf("AppSetup.WaitForOutputLine has not been set")
+	appFields := []string{"AppSetup"}
+	if len(config.Apps) == 0 {
+		validateApp(&config.AppSetup, "AppSetup", &problems)
+		checkGoToolchain([]App{config.AppSetup}, appFields, &problems)
+	} else {
+		if !reflect.ValueOf(config.AppSetup).IsZero() {
+			problems.addf("Apps", "AppSetup and Apps can't both be set")
+		}
+
+		config.Apps = append([]App{}, config.Apps...)
+		appFields = nil
+		for i := range config.Apps {
+			appFields = append(appFields, fmt.Sprintf("Apps[%d]", i))
+			validateApp(&config.Apps[i], appFields[i], &problems)
+		}
+		checkGoToolchain(config.Apps, appFields, &problems)
 	}
 
-	// default timeout to 10 seconds
-	if config.AppSetup.WaitTimeout <= 0 {
-		config.AppSetup.WaitTimeout = time.Second * 10
+	apps := orderApps(config, &problems)
+
+	for i, dbSetup := range config.DatabaseSetups {
+		checkDB(dbSetup, fmt.Sprintf("DatabaseSetups[%d]", i), config.AppRoot, &problems)
+	}
+
+	for i, dbTeardown := range config.DatabaseTeardowns {
+		checkDB(dbTeardown, fmt.Sprintf("DatabaseTeardowns[%d]", i), config.AppRoot, &problems)
+	}
+
+	for i, unitTest := range config.UnitTestSetups {
+		for j, dbSetup := range unitTest.DatabaseRoutines {
+			checkDB(dbSetup, fmt.Sprintf("UnitTestSetups[%d].DatabaseRoutines[%d]", i, j), config.AppRoot, &problems)
+		}
+	}
+
+	for i, unitTest := range config.UnitTestTeardowns {
+		for j, dbTeardown := range unitTest.DatabaseRoutines {
+			checkDB(dbTeardown, fmt.Sprintf("UnitTestTeardowns[%d].DatabaseRoutines[%d]", i, j), config.AppRoot, &problems)
+		}
+	}
+
+	for i, snapshot := range config.Snapshots {
+		if snapshot.Database == "" {
+			problems.addf(fmt.Sprintf("Snapshots[%d].Database", i), "Snapshots[%d].Database has not been set", i)
+			continue
+		}
+
+		snapshotter, err := newSnapshotter(snapshot, config.AppRoot)
+		if err != nil {
+			problems.addf(fmt.Sprintf("Snapshots[%d]", i), "Snapshots[%d]: %s", i, err.Error())
+			continue
+		}
+		problems.add(checkConnection(snapshot.Connection, fmt.Sprintf("Snapshots[%d].Connection", i)))
+		fixture.snapshots = append(fixture.snapshots, snapshotter)
+	}
+
+	for i, tracking := range config.ChangeTracking {
+		tracker, err := newChangeTracker(tracking)
+		if err != nil {
+			problems.addf(fmt.Sprintf("ChangeTracking[%d]", i), "ChangeTracking[%d]: %s", i, err.Error())
+			continue
+		}
+		problems.add(checkConnection(tracking.Connection, fmt.Sprintf("ChangeTracking[%d].Connection", i)))
+		fixture.trackers = append(fixture.trackers, tracker)
+	}
+
+	for i, check := range config.PollutionChecks {
+		checker, err := newPollutionChecker(check)
+		if err != nil {
+			problems.addf(fmt.Sprintf("PollutionChecks[%d]", i), "PollutionChecks[%d]: %s", i, err.Error())
+			continue
+		}
+		problems.add(checkConnection(check.Connection, fmt.Sprintf("PollutionChecks[%d].Connection", i)))
+		fixture.checkers = append(fixture.checkers, checker)
+	}
+
+	if !reflect.ValueOf(config.Parallel).IsZero() {
+		fixture.pool = newPool(config.Parallel, config.AppRoot, &problems)
+	}
+
+	err = problems.err()
+	if err != nil {
+		return fixture, fixture.redactor.err(err)
 	}
 
 	fixture.config = config
+	fixture.apps = apps
+	fixture.unitTestSetups = append([]UnitTest{}, config.UnitTestSetups...)
+	fixture.unitTestTeardowns = append([]UnitTest{}, config.UnitTestTeardowns...)
 
 	return fixture, nil
 }
+
+// validateApp checks an App's settings, and sets defaults.
+func validateApp(app *App, field string, problems *problems) {
+	if app.InProcess {
+		// check there's something to serve
+		if app.Handler == nil && app.Server == nil {
+			problems.addf(field+".Handler", "%s.Handler or %s.Server must be set when running InProcess", field, field)
+		}
+	} else if app.WaitForOutputLine == "" {
+		// check wait for output set
+		problems.addf(field+".WaitForOutputLine", "%s.WaitForOutputLine has not been set", field)
+	}
+
+	// default timeout to 10 seconds
+	if app.WaitTimeout <= 0 {
+		app.WaitTimeout = time.Second * 10
+	}
+
+	// default shutdown timeout to 5 seconds
+	if app.ShutdownTimeout <= 0 {
+		app.ShutdownTimeout = time.Second * 5
+	}
+
+	// check coverage profile set
+	if app.Cover && app.CoverProfile == "" {
+		problems.addf(field+".CoverProfile", "%s.CoverProfile has not been set", field)
+	}
+
+	checkBuildArguments(*app, field, problems)
+}
@@ -0,0 +1,70 @@
+package baloon
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// appOutput records the stdout and stderr output of a running App, and
+// signals when a particular line of output has been written.
+type appOutput struct {
+	mu      sync.Mutex
+	text    strings.Builder
+	waitFor string
+	found   chan struct{}
+}
+
+// watch returns a channel that is closed once line appears in the output.
+func (output *appOutput) watch(line string) <-chan struct{} {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	output.waitFor = line
+	output.found = make(chan struct{})
+
+	return output.found
+}
+
+func (output *appOutput) writeLine(line string) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	output.text.WriteString(line)
+	output.text.WriteString("\n")
+
+	if output.found != nil && line == output.waitFor {
+		close(output.found)
+		output.found = nil
+	}
+}
+
+// String returns all output written so far.
+func (output *appOutput) String() string {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	return output.text.String()
+}
+
+// lineWriter is an io.Writer that splits a stream of output into lines.
+type lineWriter struct {
+	output  *appOutput
+	partial []byte
+}
+
+func (writer *lineWriter) Write(p []byte) (int, error) {
+	writer.partial = append(writer.partial, p...)
+
+	for {
+		i := bytes.IndexByte(writer.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		writer.output.writeLine(strings.TrimSuffix(string(writer.partial[:i]), "\r"))
+		writer.partial = writer.partial[i+1:]
+	}
+
+	return len(p), nil
+}
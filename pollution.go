@@ -0,0 +1,182 @@
+package baloon
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// PollutionCheck represents a database that baloon fingerprints after Setup, and again at the
+// end of each UnitTestTeardown, to find tests that leave the database different from how it
+// was after Setup, e.g. by leaving rows behind. This is a diagnostic mode for finding tests
+// that pass alone but fail when run as part of the test suite, as it reads every table after
+// each test. Polluting tests are logged, and reported by Teardown.
+type PollutionCheck struct {
+	// Connection is the database to check.
+	Connection DBConn
+
+	// Exclude is a list of table names to not check.
+	// Names can include the schema.
+	Exclude []string
+}
+
+// tableFingerprint summarises the data in a table.
+type tableFingerprint struct {
+	rows     int
+	checksum uint64
+}
+
+// fingerprint summarises the data in each table of a database, by table name.
+type fingerprint map[string]tableFingerprint
+
+// pollutionChecker compares fingerprints of a database to find tests that change it.
+type pollutionChecker struct {
+	check    PollutionCheck
+	dialect  cleanDialect
+	baseline fingerprint
+	before   fingerprint
+}
+
+func newPollutionChecker(check PollutionCheck) (*pollutionChecker, error) {
+	d, err := dialectFor(check.Connection.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	cleanDialect, ok := d.(cleanDialect)
+	if !ok {
+		return nil, fmt.Errorf("Pollution checks aren't supported for %s databases", d.name())
+	}
+
+	return &pollutionChecker{
+		check:   check,
+		dialect: cleanDialect,
+	}, nil
+}
+
+// fingerprint reads every table in the database, counting rows and calculating a checksum.
+func (checker *pollutionChecker) fingerprint() (fingerprint, error) {
+	db, err := checker.check.Connection.open()
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to database: %s", err.Error())
+	}
+	defer db.Close()
+
+	tables, err := checker.dialect.tables(db)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting tables: %s", err.Error())
+	}
+
+	result := fingerprint{}
+	for _, t := range excludeTables(tables, checker.check.Exclude) {
+		tableFingerprint, err := fingerprintTable(db, t.quoted(checker.dialect))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading table %s: %s", t.quoted(checker.dialect), err.Error())
+		}
+		result[t.quoted(checker.dialect)] = tableFingerprint
+	}
+
+	return result, nil
+}
+
+// fingerprintTable counts the rows in a table, and sums a hash of each row,
+// so the checksum doesn't depend on the order rows are returned in.
+func fingerprintTable(db *sql.DB, name string) (tableFingerprint, error) {
+	var result tableFingerprint
+
+	rows, err := db.Query("SELECT * FROM " + name)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return result, err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		err := rows.Scan(pointers...)
+		if err != nil {
+			return result, err
+		}
+
+		hash := fnv.New64a()
+		for _, value := range values {
+			if value == nil {
+				hash.Write([]byte{0})
+			} else {
+				hash.Write([]byte{1})
+				hash.Write(value)
+			}
+		}
+
+		result.rows++
+		result.checksum += hash.Sum64()
+	}
+
+	return result, rows.Err()
+}
+
+// tableDifference describes how a table differs between two fingerprints.
+type tableDifference struct {
+	table       string
+	description string
+}
+
+// compareFingerprints returns the tables that differ between two fingerprints.
+func compareFingerprints(expected fingerprint, actual fingerprint) []tableDifference {
+	var differences []tableDifference
+
+	for name, expectedTable := range expected {
+		actualTable, exists := actual[name]
+		if !exists {
+			differences = append(differences, tableDifference{name, name + " was deleted"})
+		} else if actualTable.rows != expectedTable.rows {
+			differences = append(differences, tableDifference{name, fmt.Sprintf("%s has %d rows, but had %d rows after Setup",
+				name, actualTable.rows, expectedTable.rows)})
+		} else if actualTable.checksum != expectedTable.checksum {
+			differences = append(differences, tableDifference{name, name + " has different data to after Setup"})
+		}
+	}
+
+	for name := range actual {
+		if _, exists := expected[name]; !exists {
+			differences = append(differences, tableDifference{name, name + " was created"})
+		}
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].table < differences[j].table
+	})
+
+	return differences
+}
+
+// pollution describes the tables a test left different from the baseline. Tables that
+// were already different before the test started and that the test didn't change are
+// ignored, so tests aren't blamed for pollution left behind by earlier tests.
+func (checker *pollutionChecker) pollution(after fingerprint) []string {
+	var pollution []string
+
+	for _, difference := range compareFingerprints(checker.baseline, after) {
+		if checker.before != nil {
+			before, existed := checker.before[difference.table]
+			now, exists := after[difference.table]
+			if existed == exists && before == now {
+				continue
+			}
+		}
+
+		pollution = append(pollution, difference.description)
+	}
+
+	return pollution
+}
@@ -0,0 +1,400 @@
+package baloon
+
+import (
+	"fmt"
+	"runtime"
+	"text/template"
+)
+
+// Parallel configures a pool of isolated resources for tests that call t.Parallel(). Each
+// test leases a set of resources with Fixture.Acquire, including its own copy of each of
+// the Databases, so parallel tests don't change each other's data.
+type Parallel struct {
+	// Size is the number of resource sets in the pool, i.e. how many tests can
+	// hold a Lease at once. Defaults to runtime.GOMAXPROCS(0), which is also the
+	// default number of tests "go test" runs in parallel.
+	Size int
+
+	// Databases is a list of databases to copy for each resource set, after the
+	// DatabaseSetups have run. Supports Postgres and SQLite.
+	Databases []ParallelDatabase
+
+	// Apps starts an instance of each App for each resource set, so parallel
+	// tests don't share an App. Each instance is allocated its own port (App
+	// 'Port' is ignored), and {{database "name"}} in its 'RunArguments' and
+	// 'Env' is the name of its resource set's copy of that database.
+	Apps bool
+}
+
+// ParallelDatabase represents a database that baloon copies for each resource set in the
+// Parallel pool. Copies are restored to how they were after Setup when a Lease is returned.
+type ParallelDatabase struct {
+	// Connection is a connection used to copy the database, as in Snapshot.
+	Connection DBConn
+
+	// Database is the name of the database to copy. For SQLite this is the path
+	// of the database file, relative to AppRoot.
+	Database string
+
+	// Clone is how tests connect to a copy of the database, returned by Lease.DB.
+	// 'String' can use {{.Database}} for the name of the copy, or if 'String' isn't
+	// set, 'Database' is set to the name of the copy. 'Driver' defaults to the
+	// Connection's Driver. Not needed for SQLite, or when the Connection doesn't use
+	// 'String', as it defaults to the Connection.
+	Clone DBConn
+}
+
+// Lease is a set of isolated resources from the Parallel pool, leased to a single test by
+// Fixture.Acquire.
+type Lease struct {
+	pool      *pool
+	databases []string
+	apps      []*appRunner
+	created   bool
+	dirty     bool
+}
+
+// databaseTemplateData is the data available to templates in ParallelDatabase 'Clone'.
+type databaseTemplateData struct {
+	Database string
+}
+
+// pool hands out Leases to tests, creating and restoring copies of the Parallel Databases.
+type pool struct {
+	config    Parallel
+	databases []*snapshotter
+	leases    chan *Lease
+	all       []*Lease
+}
+
+func newPool(config Parallel, appRoot string, problems *problems) *pool {
+	if config.Size < 0 {
+		problems.addf("Parallel.Size", "Parallel.Size can't be negative")
+	}
+
+	// default to as many tests as "go test" runs at once
+	if config.Size <= 0 {
+		config.Size = runtime.GOMAXPROCS(0)
+	}
+
+	config.Databases = append([]ParallelDatabase{}, config.Databases...)
+
+	pool := &pool{
+		config: config,
+		leases: make(chan *Lease, config.Size),
+	}
+
+	for i := range config.Databases {
+		database := &config.Databases[i]
+
+		if database.Database == "" {
+			problems.addf(fmt.Sprintf("Parallel.Databases[%d].Database", i), "Parallel.Databases[%d].Database has not been set", i)
+			continue
+		}
+
+		snapshotter, err := newSnapshotter(Snapshot{Connection: database.Connection, Database: database.Database}, appRoot)
+		if err != nil {
+			problems.addf(fmt.Sprintf("Parallel.Databases[%d]", i), "Parallel.Databases[%d]: %s", i, err.Error())
+			continue
+		}
+
+		if database.Clone.Driver == "" {
+			database.Clone.Driver = database.Connection.Driver
+		}
+
+		connErr := checkConnection(database.Connection, fmt.Sprintf("Parallel.Databases[%d].Connection", i))
+		problems.add(connErr)
+
+		if database.Clone.String == "" && !database.Clone.structured() {
+			if _, ok := snapshotter.dialect.(sqliteDialect); ok {
+				// SQLite connects to the copied file
+				database.Clone.String = "{{.Database}}"
+			} else if database.Connection.String == "" && database.Connection.structured() {
+				// the copy is on the same server, with a different 'Database'
+				database.Clone = database.Connection
+			} else {
+				problems.addf(fmt.Sprintf("Parallel.Databases[%d].Clone", i), "Parallel.Databases[%d].Clone has not been set", i)
+				continue
+			}
+		}
+
+		// a Clone using the same driver has the same problems
+		if connErr == nil || database.Clone.Driver != database.Connection.Driver {
+			problems.add(checkConnection(database.Clone, fmt.Sprintf("Parallel.Databases[%d].Clone", i)))
+		}
+
+		pool.databases = append(pool.databases, snapshotter)
+	}
+
+	for i := 1; i <= config.Size; i++ {
+		lease := &Lease{pool: pool}
+
+		for _, snapshotter := range pool.databases {
+			lease.databases = append(lease.databases, fmt.Sprintf("%s_baloon_%d", snapshotter.database, i))
+		}
+
+		pool.all = append(pool.all, lease)
+		pool.leases <- lease
+	}
+
+	return pool
+}
+
+// create copies each of the Parallel Databases for every Lease in the pool.
+func (pool *pool) create() error {
+	for i, snapshotter := range pool.databases {
+		err := snapshotter.create()
+		if err != nil {
+			return fmt.Errorf("Parallel.Databases[%d]: %w", i, err)
+		}
+	}
+
+	for _, lease := range pool.all {
+		for i, snapshotter := range pool.databases {
+			snapshotter.journal.database(snapshotter.snapshot.Connection, lease.databases[i])
+		}
+
+		lease.created = true
+
+		err := lease.reset()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// drop deletes the copies of the Parallel Databases, carrying on if any fail.
+func (pool *pool) drop() error {
+	if pool == nil {
+		return nil
+	}
+
+	var steps steps
+
+	for _, lease := range pool.all {
+		if !lease.created {
+			continue
+		}
+
+		failed := false
+		for i, snapshotter := range pool.databases {
+			err := snapshotter.dropClone(lease.databases[i])
+			if err != nil {
+				steps.fail("drop parallel databases", err)
+				failed = true
+			}
+		}
+
+		lease.created = failed
+	}
+
+	for _, snapshotter := range pool.databases {
+		steps.fail("drop parallel databases", snapshotter.drop())
+	}
+
+	return steps.err()
+}
+
+// startApps starts an instance of each App for every Lease when Parallel.Apps is set.
+// Otherwise, the Leases share the Fixture's Apps.
+func (pool *pool) startApps(apps []*appRunner, funcs template.FuncMap) error {
+	for _, lease := range pool.all {
+		if !pool.config.Apps {
+			lease.apps = apps
+			continue
+		}
+
+		lease.apps = nil
+		for _, app := range apps {
+			lease.apps = append(lease.apps, app.instance())
+		}
+
+		leaseFuncs := lease.templateFuncs(funcs)
+
+		for _, app := range lease.apps {
+			err := app.allocate(leaseFuncs)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, app := range lease.apps {
+			err := app.start(leaseFuncs, AppOptions{})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// stopApps shuts down the instances of each App started by startApps, in reverse order,
+// carrying on if any fail.
+func (pool *pool) stopApps() error {
+	var steps steps
+
+	for _, app := range pool.instances() {
+		err := app.stop()
+		if err != nil {
+			steps.fail("stop app", fmt.Errorf("Error shutting down program: %w", err))
+		}
+	}
+
+	return steps.err()
+}
+
+// instances returns the instances of each App started by startApps, in the order they
+// should be shut down.
+func (pool *pool) instances() []*appRunner {
+	if pool == nil || !pool.config.Apps {
+		return nil
+	}
+
+	var instances []*appRunner
+	for _, lease := range pool.all {
+		for i := len(lease.apps) - 1; i >= 0; i-- {
+			instances = append(instances, lease.apps[i])
+		}
+	}
+
+	return instances
+}
+
+// database returns the name of one of the Parallel Databases, checking it exists.
+func (pool *pool) database(name string) (int, error) {
+	if pool != nil {
+		for i, database := range pool.config.Databases {
+			if database.Database == name {
+				return i, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("No parallel database named \"%s\"", name)
+}
+
+// acquire waits for a Lease to become free.
+func (pool *pool) acquire() *Lease {
+	return <-pool.leases
+}
+
+// release restores the Lease's databases and returns it to the pool. If restoring fails, the
+// Lease is returned anyway, and restoring is tried again when it's next acquired.
+func (pool *pool) release(lease *Lease) error {
+	defer func() {
+		pool.leases <- lease
+	}()
+
+	err := lease.reset()
+	if err != nil {
+		lease.dirty = true
+		return err
+	}
+
+	lease.dirty = false
+	return nil
+}
+
+// reset restores the Lease's copies of the Parallel Databases to how they were after Setup.
+func (lease *Lease) reset() error {
+	for i, snapshotter := range lease.pool.databases {
+		err := snapshotter.clone(lease.databases[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BaseURL returns the root URL this Lease's instance of the first App is serving HTTP
+// requests on. Without Parallel.Apps, this is the same as Fixture.BaseURL().
+func (lease *Lease) BaseURL() string {
+	app := firstApp(lease.apps)
+	if app == nil {
+		return ""
+	}
+
+	return app.baseURL()
+}
+
+// AppURL returns the root URL this Lease's instance of the App with the given name
+// is serving HTTP requests on.
+func (lease *Lease) AppURL(name string) string {
+	app, err := appNamed(lease.apps, name)
+	if err != nil {
+		return ""
+	}
+
+	return app.baseURL()
+}
+
+// Database returns the name of this Lease's copy of one of the Parallel Databases,
+// or an empty string if there's no Parallel Database with that name.
+func (lease *Lease) Database(name string) string {
+	i, err := lease.pool.database(name)
+	if err != nil {
+		return ""
+	}
+
+	return lease.databases[i]
+}
+
+// DB returns the connection to this Lease's copy of one of the Parallel Databases,
+// using the ParallelDatabase 'Clone' settings.
+func (lease *Lease) DB(name string) (DBConn, error) {
+	i, err := lease.pool.database(name)
+	if err != nil {
+		return DBConn{}, err
+	}
+
+	conn := lease.pool.config.Databases[i].Clone
+	if conn.String == "" {
+		return conn.WithDatabase(lease.databases[i])
+	}
+
+	conn.String, err = expandTemplate(conn.String, nil, databaseTemplateData{Database: lease.databases[i]})
+	if err != nil {
+		return DBConn{}, err
+	}
+
+	return conn, nil
+}
+
+// templateFuncs returns funcs with the "database", "port" and "url" funcs replaced,
+// so templates use this Lease's copies of the Parallel Databases and instances of Apps.
+func (lease *Lease) templateFuncs(funcs template.FuncMap) template.FuncMap {
+	leaseFuncs := template.FuncMap{}
+	for name, f := range funcs {
+		leaseFuncs[name] = f
+	}
+
+	leaseFuncs["database"] = func(name string) (string, error) {
+		i, err := lease.pool.database(name)
+		if err != nil {
+			return "", err
+		}
+		return lease.databases[i], nil
+	}
+
+	leaseFuncs["port"] = func(name string) (int, error) {
+		runner, err := appNamed(lease.apps, name)
+		if err != nil {
+			return 0, err
+		}
+		return runner.listenPort(), nil
+	}
+
+	leaseFuncs["url"] = func(name string) (string, error) {
+		runner, err := appNamed(lease.apps, name)
+		if err != nil {
+			return "", err
+		}
+		return runner.baseURL(), nil
+	}
+
+	return leaseFuncs
+}
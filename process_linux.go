@@ -0,0 +1,54 @@
+package baloon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// setProcessAttributes starts the App in its own process group, so that any processes
+// it starts can be shut down with it, and has it killed if the test binary dies.
+// Note that the parent-death signal is sent when the OS thread that started the
+// App exits, which for Go programs is almost always when the test binary exits.
+func setProcessAttributes(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+}
+
+// signalProcess sends a signal to the App's whole process group.
+func signalProcess(cmd *exec.Cmd, sig os.Signal) error {
+	signal, ok := sig.(syscall.Signal)
+	if !ok {
+		return cmd.Process.Signal(sig)
+	}
+
+	return syscall.Kill(-cmd.Process.Pid, signal)
+}
+
+// killProcess kills the App's whole process group.
+func killProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// killLeftoverProcess kills the process group of an App left running by an earlier test
+// run. If the PID has been reused by another program, the App's process group must have
+// ended before the PID could be reused, so there's nothing to kill.
+func killLeftoverProcess(pid int, binary string) error {
+	executable, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err == nil && strings.TrimSuffix(executable, " (deleted)") != binary {
+		return nil
+	}
+
+	// the App may have exited, but left processes it started running in its group
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
@@ -0,0 +1,80 @@
+//go:build !linux
+
+package baloon
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessAttributes does nothing on this platform.
+func setProcessAttributes(cmd *exec.Cmd) {
+}
+
+// signalProcess sends a signal to the App process.
+func signalProcess(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Signal(sig)
+}
+
+// killProcess kills the App process.
+func killProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// on Windows, FindProcess fails if there's no such process
+	if runtime.GOOS == "windows" {
+		process.Release()
+		return true
+	}
+
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// killLeftoverProcess kills an App left running by an earlier test run, if the process is
+// still running the App's executable. The PID may have been reused by another program, so
+// if the executable can't be checked, e.g. on Windows, the process is left alone.
+func killLeftoverProcess(pid int, binary string) error {
+	if runtime.GOOS == "windows" || !processAlive(pid) {
+		return nil
+	}
+
+	output, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil || !sameExecutable(strings.TrimSpace(string(output)), binary) {
+		return nil
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+
+	return process.Kill()
+}
+
+// sameExecutable reports whether command, as shown by ps, is the executable at binary.
+// ps shows the full path on macOS, but only the start of the file name on the BSDs.
+func sameExecutable(command string, binary string) bool {
+	if command == "" {
+		return false
+	}
+
+	name := filepath.Base(binary)
+	if command == binary || command == name {
+		return true
+	}
+
+	// truncated file names
+	return len(command) >= 15 && strings.HasPrefix(name, command)
+}
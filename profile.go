@@ -0,0 +1,140 @@
+package baloon
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ProfileEnv is the environment variable used to choose a profile from FixtureConfig
+// 'Profiles', when 'Profile' isn't set and the -baloon.profile test flag isn't used.
+const ProfileEnv = "BALOON_PROFILE"
+
+// ProfileFlag is the name of the test flag used to choose a profile from FixtureConfig
+// 'Profiles'. It's only used once it's registered, with RegisterProfileFlag, and the
+// flags have been parsed.
+const ProfileFlag = "baloon.profile"
+
+// RegisterProfileFlag registers the -baloon.profile test flag, which chooses a profile from
+// FixtureConfig 'Profiles' over the BALOON_PROFILE environment variable. TestMain runs before
+// the testing package parses flags, so call flag.Parse() after it, before NewFixture.
+func RegisterProfileFlag() {
+	if flag.Lookup(ProfileFlag) == nil {
+		flag.String(ProfileFlag, "", "name of the baloon FixtureConfig profile to use")
+	}
+}
+
+// selectedProfile returns the name of the profile to use: 'Profile' if set, otherwise the
+// -baloon.profile test flag, otherwise the BALOON_PROFILE environment variable.
+func (config FixtureConfig) selectedProfile() string {
+	if config.Profile != "" {
+		return config.Profile
+	}
+
+	if flag.Parsed() {
+		if profileFlag := flag.Lookup(ProfileFlag); profileFlag != nil && profileFlag.Value.String() != "" {
+			return profileFlag.Value.String()
+		}
+	}
+
+	return os.Getenv(ProfileEnv)
+}
+
+// withProfile returns the config with the selected profile merged over it.
+func (config FixtureConfig) withProfile() (FixtureConfig, error) {
+	// a profile chosen for the whole test run doesn't apply to packages without profiles
+	if len(config.Profiles) == 0 && config.Profile == "" {
+		return config, nil
+	}
+
+	name := config.selectedProfile()
+	if name == "" {
+		return config, nil
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		var names []string
+		for profileName := range config.Profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+
+		return config, newConfigError("Profile", "No profile named \"%s\", profiles are: %s", name, strings.Join(names, ", "))
+	}
+
+	profile.Profile = ""
+	profile.Profiles = nil
+
+	merged := config
+	overlay(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(profile))
+	merged.Profile = name
+
+	// secrets are added to rather than replaced, so a profile can't unmask the base secrets
+	merged.Secrets = append(append([]string{}, config.Secrets...), profile.Secrets...)
+
+	return merged, nil
+}
+
+// overlay merges the settings that are set in over into base, field by field. Structs are
+// merged recursively, lists of structs are merged item by item, maps are merged key by key,
+// and anything else is replaced if it's set in over.
+func overlay(base reflect.Value, over reflect.Value) {
+	switch base.Kind() {
+	case reflect.Struct:
+		for i := 0; i < base.NumField(); i++ {
+			overlay(base.Field(i), over.Field(i))
+		}
+
+	case reflect.Slice:
+		if over.Len() == 0 {
+			return
+		}
+
+		if base.Type().Elem().Kind() != reflect.Struct {
+			base.Set(over)
+			return
+		}
+
+		// copy, so the base config's list isn't changed
+		length := base.Len()
+		if over.Len() > length {
+			length = over.Len()
+		}
+
+		merged := reflect.MakeSlice(base.Type(), length, length)
+		reflect.Copy(merged, base)
+
+		for i := 0; i < over.Len(); i++ {
+			if i < base.Len() {
+				overlay(merged.Index(i), over.Index(i))
+			} else {
+				merged.Index(i).Set(over.Index(i))
+			}
+		}
+
+		base.Set(merged)
+
+	case reflect.Map:
+		if over.Len() == 0 {
+			return
+		}
+
+		merged := reflect.MakeMap(base.Type())
+		for _, key := range base.MapKeys() {
+			merged.SetMapIndex(key, base.MapIndex(key))
+		}
+		for _, key := range over.MapKeys() {
+			merged.SetMapIndex(key, over.MapIndex(key))
+		}
+
+		base.Set(merged)
+
+	default:
+		if !over.IsZero() {
+			base.Set(over)
+		}
+	}
+}
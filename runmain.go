@@ -0,0 +1,66 @@
+package baloon
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// RunMain creates a Fixture from config and runs the whole test suite with it, like
+// Fixture.RunMain. Use it from func TestMain(m *testing.M) when your tests don't need the
+// Fixture itself, e.g. when your App listens on a fixed Port:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(baloon.RunMain(m, config))
+//	}
+func RunMain(m *testing.M, config FixtureConfig) int {
+	fixture, err := NewFixture(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "baloon: invalid FixtureConfig: %s\n", err.Error())
+		return 1
+	}
+
+	return fixture.RunMain(m)
+}
+
+// RunMain runs the whole test suite: Setup, then the tests with m.Run(), then Teardown. It
+// returns the exit code to pass to os.Exit, which is non-zero if any tests fail, or if Setup
+// or Teardown return an error. Resources are cleaned up with Close if anything fails.
+//
+//	func TestMain(m *testing.M) {
+//		fixture, err = baloon.NewFixture(config)
+//		if err != nil {
+//			log.Panic(err)
+//		}
+//
+//		os.Exit(fixture.RunMain(m))
+//	}
+func (fixture *Fixture) RunMain(m *testing.M) (code int) {
+	defer func() {
+		err := fixture.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "baloon: Close failed: %s\n", err.Error())
+			if code == 0 {
+				code = 1
+			}
+		}
+	}()
+
+	err := fixture.Setup()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "baloon: Setup failed: %s\n", err.Error())
+		return 1
+	}
+
+	code = m.Run()
+
+	err = fixture.Teardown()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "baloon: Teardown failed: %s\n", err.Error())
+		if code == 0 {
+			code = 1
+		}
+	}
+
+	return code
+}
@@ -0,0 +1,53 @@
+package baloon
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals cleans up the Fixture with Close and exits if the test binary is sent
+// SIGINT or SIGTERM, e.g. by pressing Ctrl-C, until stopSignals is called. If the Fixture
+// is busy, e.g. Setup is building the App or waiting for a database, Close would have to
+// wait for it, so everything recorded in the journal is cleaned up instead.
+func (fixture *Fixture) handleSignals(journal *journal) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(os.Stderr, "baloon: received %s, cleaning up\n", sig)
+
+			if !fixture.mu.TryLock() {
+				journal.replay(fixture.journalConnections())
+				os.Exit(1)
+			}
+			fixture.mu.Unlock()
+
+			err := fixture.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "baloon: Close failed: %s\n", err.Error())
+			}
+			os.Exit(1)
+		case <-done:
+			signal.Stop(signals)
+		}
+	}()
+
+	fixture.stopSignals = func() {
+		close(done)
+	}
+}
+
+// stopHandlingSignals stops handleSignals, once the Fixture has been cleaned up.
+// The Fixture must be locked.
+func (fixture *Fixture) stopHandlingSignals() {
+	if fixture.stopSignals != nil {
+		fixture.stopSignals()
+		fixture.stopSignals = nil
+	}
+}
@@ -0,0 +1,229 @@
+package baloon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Snapshot represents a database that baloon copies after running the DatabaseSetups, and
+// restores from that copy at the start of each unit test. This is usually much faster than
+// running teardown and seed scripts for each unit test. Supports Postgres and SQLite.
+type Snapshot struct {
+	// Connection is a connection used to copy the database. For Postgres this must
+	// connect to a different database on the same server (e.g. "postgres"), as a
+	// database can't be copied or restored while there are connections to it. For
+	// SQLite, only the Driver is used.
+	Connection DBConn
+
+	// Database is the name of the database to snapshot, which your App should
+	// connect to. For SQLite this is the path of the database file, relative to
+	// AppRoot. SQLite databases should use the default rollback journal mode
+	// rather than WAL mode, as the file is restored while your App is running.
+	Database string
+}
+
+// snapshotDialect is implemented by dialects that can snapshot and restore databases.
+type snapshotDialect interface {
+	// createTemplate copies database to a new template database.
+	createTemplate(conn DBConn, database string, template string) error
+
+	// restoreTemplate replaces database with a copy of the template database.
+	restoreTemplate(conn DBConn, database string, template string) error
+
+	// dropTemplate deletes the template database.
+	dropTemplate(conn DBConn, template string) error
+}
+
+// snapshotter snapshots and restores a database for a Snapshot.
+type snapshotter struct {
+	snapshot Snapshot
+	dialect  snapshotDialect
+	database string
+	template string
+	created  bool
+	journal  *journal
+}
+
+func newSnapshotter(snapshot Snapshot, appRoot string) (*snapshotter, error) {
+	d, err := dialectFor(snapshot.Connection.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotDialect, ok := d.(snapshotDialect)
+	if !ok {
+		return nil, fmt.Errorf("%s databases can't be snapshotted", d.name())
+	}
+
+	database := snapshot.Database
+	if _, ok := d.(sqliteDialect); ok && !filepath.IsAbs(database) {
+		database = filepath.Join(appRoot, database)
+	}
+
+	return &snapshotter{
+		snapshot: snapshot,
+		dialect:  snapshotDialect,
+		database: database,
+		template: database + "_baloon_template",
+	}, nil
+}
+
+func (snapshotter *snapshotter) create() error {
+	snapshotter.journal.database(snapshotter.snapshot.Connection, snapshotter.template)
+
+	err := snapshotter.dialect.createTemplate(snapshotter.snapshot.Connection, snapshotter.database, snapshotter.template)
+	if err != nil {
+		return fmt.Errorf("Error creating snapshot of database \"%s\": %s", snapshotter.snapshot.Database, err.Error())
+	}
+
+	snapshotter.created = true
+	return nil
+}
+
+func (snapshotter *snapshotter) restore() error {
+	err := snapshotter.dialect.restoreTemplate(snapshotter.snapshot.Connection, snapshotter.database, snapshotter.template)
+	if err != nil {
+		return fmt.Errorf("Error restoring snapshot of database \"%s\": %s", snapshotter.snapshot.Database, err.Error())
+	}
+
+	return nil
+}
+
+func (snapshotter *snapshotter) drop() error {
+	if !snapshotter.created {
+		return nil
+	}
+
+	err := snapshotter.dialect.dropTemplate(snapshotter.snapshot.Connection, snapshotter.template)
+	if err != nil {
+		return fmt.Errorf("Error deleting snapshot of database \"%s\": %s", snapshotter.snapshot.Database, err.Error())
+	}
+
+	snapshotter.created = false
+	return nil
+}
+
+// clone replaces the database with the given name with a copy of the snapshot.
+func (snapshotter *snapshotter) clone(name string) error {
+	err := snapshotter.dialect.restoreTemplate(snapshotter.snapshot.Connection, name, snapshotter.template)
+	if err != nil {
+		return fmt.Errorf("Error copying snapshot of database \"%s\": %s", snapshotter.snapshot.Database, err.Error())
+	}
+
+	return nil
+}
+
+// dropClone deletes a copy of the snapshot made with clone.
+func (snapshotter *snapshotter) dropClone(name string) error {
+	err := snapshotter.dialect.dropTemplate(snapshotter.snapshot.Connection, name)
+	if err != nil {
+		return fmt.Errorf("Error deleting copy of database \"%s\": %s", snapshotter.snapshot.Database, err.Error())
+	}
+
+	return nil
+}
+
+// execAll opens a connection and runs each command in turn.
+func execAll(conn DBConn, commands ...string) error {
+	db, err := conn.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, command := range commands {
+		_, err := db.Exec(command)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d postgresDialect) createTemplate(conn DBConn, database string, template string) error {
+	// a template left behind by a run that crashed can't be dropped until it's unmarked
+	err := d.unmarkTemplate(conn, template)
+	if err != nil {
+		return err
+	}
+
+	return execAll(conn,
+		"DROP DATABASE IF EXISTS "+d.quote(template)+";",
+		"CREATE DATABASE "+d.quote(template)+" TEMPLATE "+d.quote(database)+";",
+		"ALTER DATABASE "+d.quote(template)+" WITH IS_TEMPLATE true;",
+	)
+}
+
+func (d postgresDialect) restoreTemplate(conn DBConn, database string, template string) error {
+	db, err := conn.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// terminates connections from the App first
+	err = d.dropDatabase(db, database)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("CREATE DATABASE " + d.quote(database) + " TEMPLATE " + d.quote(template) + ";")
+	return err
+}
+
+func (d postgresDialect) dropTemplate(conn DBConn, template string) error {
+	err := d.unmarkTemplate(conn, template)
+	if err != nil {
+		return err
+	}
+
+	return execAll(conn, "DROP DATABASE IF EXISTS "+d.quote(template)+";")
+}
+
+// unmarkTemplate turns off IS_TEMPLATE for the template database, if it exists, so it can
+// be dropped.
+func (d postgresDialect) unmarkTemplate(conn DBConn, template string) error {
+	db, err := conn.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	exists, err := d.databaseExists(db, template)
+	if err != nil || !exists {
+		return err
+	}
+
+	_, err = db.Exec("ALTER DATABASE " + d.quote(template) + " WITH IS_TEMPLATE false;")
+	return err
+}
+
+func (sqliteDialect) createTemplate(conn DBConn, database string, template string) error {
+	return copyFile(database, template)
+}
+
+func (sqliteDialect) restoreTemplate(conn DBConn, database string, template string) error {
+	// overwrite in place, so open connections from the App see the restored data
+	return copyFile(template, database)
+}
+
+func (sqliteDialect) dropTemplate(conn DBConn, template string) error {
+	err := os.Remove(template)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// copyFile copies the contents of one file over another.
+func copyFile(from string, to string) error {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(to, data, 0644)
+}
@@ -0,0 +1,140 @@
+package baloon
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// appTemplateData is the data available to templates in App settings such as 'RunArguments'.
+type appTemplateData struct {
+	Name string
+	Port int
+	URL  string
+}
+
+// templateFuncs returns the funcs available to templates, giving access
+// to fixture Variables, the ports and URLs of Apps, and Parallel Databases.
+func (fixture *Fixture) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"var": func(name string) (string, error) {
+			value, ok := fixture.config.Variables[name]
+			if !ok {
+				return "", fmt.Errorf("No variable named \"%s\"", name)
+			}
+			return value, nil
+		},
+		"port": func(name string) (int, error) {
+			runner, err := fixture.appNamed(name)
+			if err != nil {
+				return 0, err
+			}
+			return runner.listenPort(), nil
+		},
+		"url": func(name string) (string, error) {
+			runner, err := fixture.appNamed(name)
+			if err != nil {
+				return "", err
+			}
+			return runner.baseURL(), nil
+		},
+		"database": func(name string) (string, error) {
+			_, err := fixture.pool.database(name)
+			if err != nil {
+				return "", err
+			}
+			return name, nil
+		},
+	}
+}
+
+// expandTemplate executes text as a text/template, if it contains any template actions.
+func expandTemplate(text string, funcs template.FuncMap, data interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing template \"%s\": %s", text, err.Error())
+	}
+
+	var output strings.Builder
+	err = tmpl.Execute(&output, data)
+	if err != nil {
+		return "", fmt.Errorf("Error executing template \"%s\": %s", text, err.Error())
+	}
+
+	return output.String(), nil
+}
+
+// scriptFuncs are the funcs available to templated Scripts, as well as the fixture's funcs.
+var scriptFuncs = template.FuncMap{
+	"now":  time.Now,
+	"uuid": newUUID,
+	"random": func(length int) (string, error) {
+		const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+		random := make([]byte, length)
+		for i := range random {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+			if err != nil {
+				return "", err
+			}
+			random[i] = chars[n.Int64()]
+		}
+		return string(random), nil
+	},
+	"env": func(name string, defaultValue ...string) (string, error) {
+		value, ok := os.LookupEnv(name)
+		if ok {
+			return value, nil
+		}
+		if len(defaultValue) > 0 {
+			return defaultValue[0], nil
+		}
+		return "", fmt.Errorf("Environment variable \"%s\" has not been set", name)
+	},
+	"bcrypt": func(password string) (string, error) {
+		// the minimum cost keeps seeding fast, and hashes still check against any cost
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		return string(hash), err
+	},
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() (string, error) {
+	var uuid [16]byte
+	_, err := rand.Read(uuid[:])
+	if err != nil {
+		return "", err
+	}
+
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
+
+// executeScriptTemplate executes a templated Script's text, where name is used in errors
+// to say where a problem is, e.g. "template: seed.sql:3: ...".
+func executeScriptTemplate(name string, text string, funcs template.FuncMap) (string, error) {
+	tmpl, err := template.New(name).Funcs(scriptFuncs).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	err = tmpl.Execute(&output, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return output.String(), nil
+}
@@ -0,0 +1,102 @@
+package baloon_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestCreateAndDropDatabase(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	server := baloon.DBConn{Driver: "sqlite3"}
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	// database names for SQLite are relative to AppRoot
+	relativePath, err := filepath.Rel(appRootPath, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: server,
+				Scripts: []baloon.Script{
+					baloon.NewDropDatabaseScript(relativePath),
+					baloon.NewCreateDatabaseScript(relativePath),
+					// already exists
+					baloon.NewCreateDatabaseScript(dbPath),
+				},
+			},
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);"),
+			},
+		},
+		AppSetup: inProcessApp,
+		DatabaseTeardowns: []baloon.DB{
+			{
+				Connection: server,
+				Script:     baloon.NewDropDatabaseScript(dbPath),
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := fixture.DatabaseExists(server, relativePath)
+	if err != nil || !exists {
+		t.Errorf("Should create the database, but DatabaseExists returned %v, %v", exists, err)
+	}
+
+	if count := countRows(t, conn, "sqlite_master WHERE name = 'customers'"); count != 1 {
+		t.Errorf("Should keep the database when it already exists")
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Errorf("Should drop the database during Teardown")
+	}
+
+	exists, err = fixture.DatabaseExists(server, relativePath)
+	if err != nil || exists {
+		t.Errorf("Should not find dropped database, but DatabaseExists returned %v, %v", exists, err)
+	}
+}
+
+func TestCreateDatabaseNotSet(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	_, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: baloon.DBConn{Driver: "sqlite3"},
+				Script:     baloon.NewCreateDatabaseScript(""),
+			},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	var configErr *baloon.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "DatabaseSetups[0].Script" {
+		t.Errorf("Should return error when the database name isn't set, but got: %v", err)
+	}
+}
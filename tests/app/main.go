@@ -3,12 +3,71 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	var readyMessage string
+	var dataRace bool
+	var port int
+	var message string
+	var helper bool
 	flag.StringVar(&readyMessage, "ready_statement", "", "")
+	flag.BoolVar(&dataRace, "data_race", false, "")
+	flag.IntVar(&port, "port", 0, "")
+	flag.StringVar(&message, "message", os.Getenv("APP_MESSAGE"), "")
+	flag.BoolVar(&helper, "helper", false, "")
 	flag.Parse()
 
+	if helper {
+		cmd := exec.Command("sleep", "60")
+		err := cmd.Start()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("helper=%d\n", cmd.Process.Pid)
+	}
+
+	if dataRace {
+		causeDataRace()
+	}
+
+	if port != 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(message))
+		}))
+	}
+
 	fmt.Println(readyMessage)
+
+	// keep running until we're asked to shut down
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+}
+
+func causeDataRace() {
+	counter := 0
+	done := make(chan struct{})
+
+	go func() {
+		counter++
+		close(done)
+	}()
+
+	counter++
+	<-done
+	time.Sleep(10 * time.Millisecond)
 }
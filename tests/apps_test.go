@@ -0,0 +1,118 @@
+package baloon_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestMultipleApps(t *testing.T) {
+	testRootPath, _ := filepath.Abs("./")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: testRootPath,
+		Apps: []baloon.App{
+			{
+				Name:      "api",
+				Root:      "./app",
+				DependsOn: []string{"auth"},
+				RunArguments: []string{
+					"-port", "{{.Port}}",
+					"-message", `auth={{url "auth"}} {{var "greeting"}}`,
+					"-ready_statement", "API Running",
+				},
+				WaitForOutputLine: "API Running",
+				WaitTimeout:       time.Second * 2,
+			},
+			{
+				Name: "auth",
+				Root: "./app",
+				RunArguments: []string{
+					"-port", "{{.Port}}",
+					"-message", "auth",
+					"-ready_statement", "Auth Running",
+				},
+				WaitForOutputLine: "Auth Running",
+				WaitTimeout:       time.Second * 2,
+			},
+		},
+		Variables: map[string]string{
+			"greeting": "hello",
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fixture.BaseURL() != fixture.AppURL("api") {
+		t.Errorf("BaseURL() should be the URL of the first App, but got '%s'", fixture.BaseURL())
+	}
+
+	if body := getBody(t, fixture.AppURL("auth")); body != "auth" {
+		t.Errorf("Should run the auth App, but got '%s'", body)
+	}
+
+	expected := "auth=" + fixture.AppURL("auth") + " hello"
+	if body := getBody(t, fixture.BaseURL()); body != expected {
+		t.Errorf("Should pass the auth App URL to the api App as '%s', but got '%s'", expected, body)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppDependencies(t *testing.T) {
+	testRootPath, _ := filepath.Abs("./")
+
+	tests := []struct {
+		Message       string
+		Apps          []baloon.App
+		ContainsError string
+	}{
+		{
+			Message: "Should return error when an App has no Name.",
+			Apps: []baloon.App{
+				{Name: "api", WaitForOutputLine: "Running"},
+				{WaitForOutputLine: "Running"},
+			},
+			ContainsError: "Apps[1].Name has not been set",
+		},
+		{
+			Message: "Should return error when depending on an unknown App.",
+			Apps: []baloon.App{
+				{Name: "api", WaitForOutputLine: "Running", DependsOn: []string{"nope"}},
+			},
+			ContainsError: "unknown App \"nope\"",
+		},
+		{
+			Message: "Should return error when Apps depend on each other.",
+			Apps: []baloon.App{
+				{Name: "api", WaitForOutputLine: "Running", DependsOn: []string{"auth"}},
+				{Name: "auth", WaitForOutputLine: "Running", DependsOn: []string{"api"}},
+			},
+			ContainsError: "circular dependency: api -> auth -> api",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := baloon.NewFixture(baloon.FixtureConfig{
+			AppRoot: testRootPath,
+			Apps:    test.Apps,
+		})
+		if err == nil || !strings.Contains(err.Error(), test.ContainsError) {
+			t.Error(test.Message)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package baloon_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestBegin(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);"),
+			},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setups := 0
+	teardowns := 0
+
+	fixture.AddUnitTestSetup(baloon.UnitTest{
+		DatabaseRoutines: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("INSERT INTO customers (name) VALUES ('Alice');"),
+			},
+		},
+		Func: func(tb testing.TB) {
+			setups++
+		},
+	})
+
+	fixture.AddUnitTestTeardown(baloon.UnitTest{
+		DatabaseRoutines: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewCleanTablesScript(),
+			},
+		},
+		Func: func(tb testing.TB) {
+			teardowns++
+		},
+	})
+
+	t.Run("Test", func(t *testing.T) {
+		fixture.Begin(t)
+
+		if count := countRows(t, conn, "customers"); count != 1 {
+			t.Errorf("Should run the unit test setups, but got %d customers", count)
+		}
+	})
+
+	if setups != 1 || teardowns != 1 {
+		t.Errorf("Should run unit test setups and teardowns once each, but got %d setups and %d teardowns", setups, teardowns)
+	}
+
+	if count := countRows(t, conn, "customers"); count != 0 {
+		t.Errorf("Should run the unit test teardowns when the test completes, but got %d customers", count)
+	}
+
+	testing.Benchmark(func(b *testing.B) {
+		fixture.Begin(b)
+
+		for i := 0; i < b.N; i++ {
+			countRows(t, conn, "customers")
+		}
+	})
+
+	if setups < 2 || setups != teardowns {
+		t.Errorf("Should run unit test setups and teardowns for benchmarks, but got %d setups and %d teardowns", setups, teardowns)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
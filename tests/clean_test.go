@@ -0,0 +1,86 @@
+package baloon_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestCleanTablesScript(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath + "?_foreign_keys=on"}
+
+	// orders reference customers, so must be deleted first
+	execSQL(t, conn, `
+		CREATE TABLE customers (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT);
+		CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, customer_id INTEGER REFERENCES customers (id));
+		CREATE TABLE settings (name TEXT);
+		INSERT INTO customers (name) VALUES ('Alice'), ('Bob');
+		INSERT INTO orders (customer_id) VALUES (1), (2);
+		INSERT INTO settings (name) VALUES ('theme');`)
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot:  appRootPath,
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	fixture.AddUnitTestTeardown(baloon.UnitTest{
+		DatabaseRoutines: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewCleanTablesScript("settings"),
+			},
+		},
+	})
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture.UnitTestTeardown(t)
+
+	if count := countRows(t, conn, "customers"); count != 0 {
+		t.Errorf("Should delete all customers, but got %d", count)
+	}
+
+	if count := countRows(t, conn, "orders"); count != 0 {
+		t.Errorf("Should delete all orders, but got %d", count)
+	}
+
+	if count := countRows(t, conn, "settings"); count != 1 {
+		t.Errorf("Should leave excluded tables alone, but got %d settings", count)
+	}
+
+	// auto-increment counters should start again
+	execSQL(t, conn, "INSERT INTO customers (name) VALUES ('Carol');")
+
+	db, err := sql.Open(conn.Driver, conn.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var id int
+	err = db.QueryRow("SELECT id FROM customers").Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 1 {
+		t.Errorf("Should reset auto-increment counters, but new row had id %d", id)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
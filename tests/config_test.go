@@ -0,0 +1,167 @@
+package baloon_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestLoadConfig(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	configPath, _ := filepath.Abs("./testdata/config/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	t.Setenv("CONFIG_DB", dbPath)
+
+	config, err := baloon.LoadConfig("./testdata/config/baloon.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.AppRoot != appRootPath {
+		t.Errorf("Should resolve AppRoot relative to the config file, but was: %s", config.AppRoot)
+	}
+
+	if config.DatabaseSetups[0].Connection != conn {
+		t.Errorf("Should replace environment variables, but Connection was: %v", config.DatabaseSetups[0].Connection)
+	}
+
+	if script := config.DatabaseSetups[0].Scripts[1]; script.Type != baloon.ScriptTypePath || script.Command != filepath.Join(configPath, "sql/*.sql") {
+		t.Errorf("Should resolve script paths relative to the config file, but was: %v", script)
+	}
+
+	if config.AppSetup.WaitTimeout != time.Second*5 {
+		t.Errorf("Should parse durations, but WaitTimeout was: %s", config.AppSetup.WaitTimeout)
+	}
+
+	if config.AppSetup.CoverProfile != filepath.Join(configPath, "cover.out") {
+		t.Errorf("Should use defaults for environment variables that aren't set, but CoverProfile was: %s", config.AppSetup.CoverProfile)
+	}
+
+	if ci := config.Profiles["ci"].AppSetup; !ci.Race || ci.WaitTimeout != time.Second*30 {
+		t.Errorf("Should read Profiles, but got: %+v", config.Profiles)
+	}
+
+	if config.Variables["greeting"] != "hello" {
+		t.Errorf("Should read Variables, but got: %v", config.Variables)
+	}
+
+	if len(config.Secrets) != 1 || config.Secrets[0] != "not-a-real-key" {
+		t.Errorf("Should read Secrets, but got: %v", config.Secrets)
+	}
+
+	tomlConfig, err := baloon.LoadConfig("./testdata/config/baloon.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(config, tomlConfig) {
+		t.Errorf("Should read the same config from TOML.\nYAML: %+v\nTOML: %+v", config, tomlConfig)
+	}
+
+	config.AppSetup = inProcessApp
+
+	fixture, err := baloon.NewFixture(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countRows(t, conn, "customers"); count != 2 {
+		t.Errorf("Should run the script files, but found %d customers", count)
+	}
+
+	t.Run("UnitTest", func(t *testing.T) {
+		fixture.Begin(t)
+
+		if count := countRows(t, conn, "customers"); count != 0 {
+			t.Errorf("Should run the unit test setups, but found %d customers", count)
+		}
+	})
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countRows(t, conn, "sqlite_master WHERE name = 'customers'"); count != 0 {
+		t.Errorf("Should run the database teardowns")
+	}
+}
+
+func TestLoadConfigInProcess(t *testing.T) {
+	// the Handler can't be written in a file, so it's set after loading
+	config, err := baloon.LoadConfig("./testdata/config/inprocess.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !config.AppSetup.InProcess {
+		t.Errorf("Should read in_process, but AppSetup was: %+v", config.AppSetup)
+	}
+
+	_, err = baloon.NewFixture(config)
+	if err == nil || !strings.Contains(err.Error(), "AppSetup.Handler or AppSetup.Server must be set when running InProcess") {
+		t.Errorf("NewFixture should check the config, but got: %v", err)
+	}
+
+	config.AppSetup.Handler = helloHandler
+
+	fixture, err := baloon.NewFixture(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	_, err := baloon.LoadConfig("./testdata/config/baloon.yaml")
+	if err == nil || err.Error() != "Environment variables used in config file have not been set: CONFIG_DB" {
+		t.Errorf("Should return error for environment variables that aren't set, but got: %v", err)
+	}
+
+	t.Setenv("CONFIG_DB", filepath.Join(t.TempDir(), "northwind.db"))
+
+	// only the selected profile needs its environment variables set
+	t.Setenv(baloon.ProfileEnv, "ci")
+
+	_, err = baloon.LoadConfig("./testdata/config/baloon.yaml")
+	if err == nil || err.Error() != "Environment variables used in config file have not been set: CONFIG_CI_SECRET" {
+		t.Errorf("Should return error for environment variables in the selected profile that aren't set, but got: %v", err)
+	}
+
+	t.Setenv(baloon.ProfileEnv, "")
+
+	_, err = baloon.LoadConfig("./testdata/config/invalid.yaml")
+
+	var multiErr *baloon.MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+		t.Fatalf("Should return every problem at once, but got: %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "DatabaseSetups[0].Scripts[0] must set one of sql, path, clean_tables, create_database or drop_database") ||
+		!strings.Contains(err.Error(), "AppSetup.WaitTimeout \"soon\" is not a valid duration") {
+		t.Errorf("Wrong error returned for invalid config. Error was: %s", err.Error())
+	}
+
+	_, err = baloon.LoadConfig("./testdata/config/sql/customers.sql")
+	if err == nil || !strings.Contains(err.Error(), "Unsupported config file") {
+		t.Errorf("Should return error for unsupported file types, but got: %v", err)
+	}
+}
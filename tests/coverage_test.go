@@ -0,0 +1,87 @@
+package baloon_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestRaceBuild(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			RunArguments: []string{
+				"-ready_statement", "Running",
+				"-data_race",
+			},
+			WaitForOutputLine: "Running",
+			WaitTimeout:       time.Second * 30,
+			Race:              true,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fixture.Teardown()
+	if err == nil {
+		t.Errorf("Should return an error when the program has data races")
+	} else if !strings.Contains(err.Error(), "WARNING: DATA RACE") {
+		t.Errorf("Error should contain the race detector report. Error was: %s", err.Error())
+	}
+}
+
+func TestCoverBuild(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	profile := filepath.Join(t.TempDir(), "coverage.out")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			RunArguments: []string{
+				"-ready_statement", "Running",
+			},
+			WaitForOutputLine: "Running",
+			WaitTimeout:       time.Second * 10,
+			Cover:             true,
+			CoverProfile:      profile,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(profile)
+	if err != nil {
+		t.Fatalf("Should write a coverage profile, but got error: %s", err.Error())
+	}
+
+	if !strings.HasPrefix(string(data), "mode: ") {
+		t.Errorf("Coverage profile is not in the standard format: %s", data)
+	}
+}
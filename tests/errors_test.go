@@ -0,0 +1,121 @@
+package baloon_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestTeardownCarriesOn(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);"),
+			},
+		},
+		AppSetup: inProcessApp,
+		DatabaseTeardowns: []baloon.DB{
+			{
+				Connection: baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "missing", "northwind.db")},
+				Script:     baloon.NewScript("DROP TABLE customers;"),
+			},
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("DROP TABLE customers;"),
+			},
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("DROP TABLE orders;"),
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fixture.Teardown()
+	if err == nil {
+		t.Fatal("Should return an error when Database Teardowns fail")
+	}
+
+	var multiErr *baloon.MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("Should return a MultiError, but got %T", err)
+	}
+
+	if len(multiErr.Errors) != 2 {
+		t.Errorf("Should return every failure, but got %d: %s", len(multiErr.Errors), err.Error())
+	}
+
+	var stepErr *baloon.StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Should return StepErrors")
+	}
+
+	if stepErr.Step != "database teardown" {
+		t.Errorf("Wrong step for failure. Step was: %s", stepErr.Step)
+	}
+
+	if !strings.Contains(err.Error(), "Error running Database Teardown at index 0") ||
+		!strings.Contains(err.Error(), "Error running Database Teardown at index 2") {
+		t.Errorf("Should include every failure in the error message. Error was: %s", err.Error())
+	}
+
+	if count := countRows(t, conn, "sqlite_master WHERE name = 'customers'"); count != 0 {
+		t.Errorf("Should run the Database Teardowns after the one that failed")
+	}
+
+	err = fixture.Close()
+	if err != nil {
+		t.Errorf("Close should have nothing left to free, but got error: %s", err.Error())
+	}
+}
+
+func TestLifecycleErrors(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot:  appRootPath,
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.StopApp("")
+	if !errors.Is(err, baloon.ErrNotSetup) {
+		t.Errorf("Should return ErrNotSetup when calling StopApp() before Setup(), but got: %v", err)
+	}
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fixture.StartApp(baloon.AppOptions{})
+	if !errors.Is(err, baloon.ErrAlreadyTornDown) {
+		t.Errorf("Should return ErrAlreadyTornDown when calling StartApp() after Teardown(), but got: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package baloon_test
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,11 +19,13 @@ func TestNewFixture(t *testing.T) {
 		Config        baloon.FixtureConfig
 		ReturnsError  string
 		ContainsError string
+		Field         string
 	}{
 		{
-			Message:      "Should return error when AppRoot is missing.",
-			Config:       baloon.FixtureConfig{},
-			ReturnsError: "AppRoot is missing",
+			Message:       "Should return error when AppRoot is missing.",
+			Config:        baloon.FixtureConfig{},
+			ContainsError: "AppRoot is missing",
+			Field:         "AppRoot",
 		},
 		{
 			Message: "Should return error when AppRoot is not an absolute path.",
@@ -30,13 +33,15 @@ func TestNewFixture(t *testing.T) {
 				AppRoot: "./",
 			},
 			ContainsError: "Please use an absolute path",
+			Field:         "AppRoot",
 		},
 		{
 			Message: "Should return error when AppRoot path doesn't exist.",
 			Config: baloon.FixtureConfig{
 				AppRoot: "/blah/blah/nonsense",
 			},
-			ReturnsError: "AppRoot directory does not exist",
+			ContainsError: "AppRoot directory does not exist",
+			Field:         "AppRoot",
 		},
 		{
 			Message: "Should return error when AppSetup.WaitForOutputLine has not been set",
@@ -44,6 +49,7 @@ func TestNewFixture(t *testing.T) {
 				AppRoot: testRootPath,
 			},
 			ReturnsError: "AppSetup.WaitForOutputLine has not been set",
+			Field:        "AppSetup.WaitForOutputLine",
 		},
 	}
 
@@ -51,13 +57,139 @@ func TestNewFixture(t *testing.T) {
 		_, err := baloon.NewFixture(test.Config)
 		if test.ReturnsError != "" {
 			if err == nil || err.Error() != test.ReturnsError {
-				t.Errorf(test.Message)
+				t.Error(test.Message)
 			}
 		} else {
 			if err == nil || !strings.Contains(err.Error(), test.ContainsError) {
-				t.Errorf(test.Message)
+				t.Error(test.Message)
 			}
 		}
+
+		var configErr *baloon.ConfigError
+		if !errors.As(err, &configErr) {
+			t.Errorf("%s Should return a ConfigError.", test.Message)
+		} else if configErr.Field != test.Field {
+			t.Errorf("%s Wrong Field in ConfigError. Field was: %s", test.Message, configErr.Field)
+		}
+	}
+}
+
+func TestNewFixtureProblems(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	_, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: baloon.DBConn{Driver: "postgress"},
+				Scripts: []baloon.Script{
+					baloon.NewScriptPath("*.go"),
+					baloon.NewScriptPath("scripts/*.sql"),
+					{Type: 9, Command: "SELECT 1"},
+				},
+			},
+		},
+		AppSetup: baloon.App{
+			BuildArguments:    []string{"-o"},
+			WaitForOutputLine: "Running",
+		},
+	})
+
+	var multiErr *baloon.MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("Should return a MultiError, but got: %v", err)
+	}
+
+	expected := []string{
+		"AppSetup.BuildArguments",
+		"DatabaseSetups[0].Connection.Driver",
+		"DatabaseSetups[0].Scripts[1]",
+		"DatabaseSetups[0].Scripts[2]",
+	}
+
+	if len(multiErr.Errors) != len(expected) {
+		t.Fatalf("Should return every problem at once, but got %d: %s", len(multiErr.Errors), err.Error())
+	}
+
+	for i, field := range expected {
+		var configErr *baloon.ConfigError
+		if !errors.As(multiErr.Errors[i], &configErr) {
+			t.Errorf("Should return a ConfigError for %s, but got: %v", field, multiErr.Errors[i])
+		} else if configErr.Field != field {
+			t.Errorf("Wrong Field in ConfigError. Expected %s, but was: %s", field, configErr.Field)
+		}
+	}
+
+	if !strings.Contains(err.Error(), "\"postgress\" has not been registered") {
+		t.Errorf("Should report drivers that haven't been registered. Error was: %s", err.Error())
+	}
+
+	// the go command is only needed to build Apps
+	t.Setenv("PATH", "")
+
+	_, err = baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			WaitForOutputLine: "Running",
+		},
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "The go command is needed to build AppSetup") {
+		t.Errorf("Should return error when the go command can't be found, but got: %v", err)
+	}
+
+	_, err = baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot:  appRootPath,
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Errorf("Should not need the go command for InProcess Apps, but got: %s", err.Error())
+	}
+}
+
+func TestNewFixtureAllProblems(t *testing.T) {
+	_, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: "/blah/blah/nonsense",
+		Apps: []baloon.App{
+			{Name: "api", InProcess: true, Handler: helloHandler, DependsOn: []string{"nope"}},
+			{Name: "api", InProcess: true, Handler: helloHandler},
+		},
+		Parallel: baloon.Parallel{
+			Size: -1,
+			Databases: []baloon.ParallelDatabase{
+				{Connection: baloon.DBConn{Driver: "sqlite3"}},
+				{Connection: baloon.DBConn{Driver: "postgres"}, Database: "northwind"},
+			},
+		},
+	})
+
+	var multiErr *baloon.MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("Should return a MultiError, but got: %v", err)
+	}
+
+	expected := []string{
+		"AppRoot",
+		"Apps[1].Name",
+		"Apps[0].DependsOn",
+		"Parallel.Size",
+		"Parallel.Databases[0].Database",
+		"Parallel.Databases[1].Connection.Driver",
+		"Parallel.Databases[1].Clone",
+	}
+
+	if len(multiErr.Errors) != len(expected) {
+		t.Fatalf("Should return every problem at once, but got %d: %s", len(multiErr.Errors), err.Error())
+	}
+
+	for i, field := range expected {
+		var configErr *baloon.ConfigError
+		if !errors.As(multiErr.Errors[i], &configErr) {
+			t.Errorf("Should return a ConfigError for %s, but got: %v", field, multiErr.Errors[i])
+		} else if configErr.Field != field {
+			t.Errorf("Wrong Field in ConfigError. Expected %s, but was: %s", field, configErr.Field)
+		}
 	}
 }
 
@@ -88,7 +220,7 @@ func TestFixtureSetup(t *testing.T) {
 	err = fixture.Setup()
 	if err == nil {
 		t.Errorf("Should get an error if running Setup() more than once.")
-	} else if err.Error() != "Setup() has already been called. Only run this function once for the test suite." {
+	} else if !errors.Is(err, baloon.ErrAlreadySetup) {
 		t.Errorf("Wrong error returned when running Setup() more than once. Error was: %s", err.Error())
 	}
 
@@ -113,7 +245,7 @@ func TestFixtureSetup(t *testing.T) {
 	err = fixture.Setup()
 	if err == nil {
 		t.Errorf("Should return error about program timeout")
-	} else if err.Error() != "Timeout waiting for program to start. Was looking for output line \"Running\"." {
+	} else if !errors.Is(err, baloon.ErrStartupTimeout) {
 		t.Errorf("Wrong error returned about program timeout. Error was: %s", err.Error())
 	}
 
@@ -152,7 +284,7 @@ func TestFixtureTeardown(t *testing.T) {
 	err = fixture.Teardown()
 	if err == nil {
 		t.Errorf("Should return an error if attempting to run Teardown() twice")
-	} else if err.Error() != "Teardown() has already been called. Only run this function once for the test suite." {
+	} else if !errors.Is(err, baloon.ErrAlreadyTornDown) {
 		t.Errorf("Wrong error returned when running Teardown() more than once. Error was: %s", err.Error())
 	}
 
@@ -177,7 +309,7 @@ func TestFixtureTeardown(t *testing.T) {
 	err = fixture.Teardown()
 	if err == nil {
 		t.Errorf("Should return an error if attempting to run Teardown() before Setup()")
-	} else if err.Error() != "Please run Setup() first before calling Teardown()" {
+	} else if !errors.Is(err, baloon.ErrNotSetup) {
 		t.Errorf("Wrong error returned when running Teardown() before Setup(). Error was: %s", err.Error())
 	}
 
@@ -228,3 +360,61 @@ func TestBuildArguments(t *testing.T) {
 		t.Errorf("Should delete program executable afterward.")
 	}
 }
+
+func TestBuildOutputArgumentForms(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	for _, buildArguments := range [][]string{
+		{"-o=./baloon_test_equals"},
+		{"--o", "./baloon_test_dashes"},
+		{"--o=./baloon_test_dashes_equals"},
+	} {
+		programName := buildArguments[len(buildArguments)-1]
+		programName = programName[strings.LastIndex(programName, "/")+1:]
+
+		fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+			AppRoot: appRootPath,
+			AppSetup: baloon.App{
+				BuildArguments: buildArguments,
+				RunArguments: []string{
+					"-ready_statement", "Running",
+				},
+				WaitForOutputLine: "Running",
+				WaitTimeout:       time.Second * 2,
+			},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = fixture.Setup()
+		if err != nil {
+			fixture.Close()
+			t.Fatalf("Should build and run the program with BuildArguments %v, but got error: %s", buildArguments, err.Error())
+		}
+
+		_, err = os.Stat(filepath.Join(appRootPath, programName))
+		if err != nil {
+			t.Errorf("Should generate program executable '%s' for BuildArguments %v", programName, buildArguments)
+		}
+
+		err = fixture.Teardown()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			BuildArguments:    []string{"-o="},
+			WaitForOutputLine: "Running",
+		},
+	})
+
+	var configErr *baloon.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "AppSetup.BuildArguments" {
+		t.Errorf("Should return error for -o= without a value, but got: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package baloon_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sironfoot/baloon"
+)
+
+var helloHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("Hello world"))
+})
+
+func getBody(t *testing.T, url string) string {
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestInProcessHandler(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			InProcess: true,
+			Handler:   helloHandler,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body := getBody(t, fixture.BaseURL()); body != "Hello world" {
+		t.Errorf("Should serve the Handler from BaseURL(), but got '%s'", body)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInProcessServer(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	stopped := false
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			InProcess: true,
+			Server: func(ctx context.Context, addr string) error {
+				listener, err := net.Listen("tcp", addr)
+				if err != nil {
+					return err
+				}
+
+				server := &http.Server{Handler: helloHandler}
+				go func() {
+					<-ctx.Done()
+					server.Shutdown(context.Background())
+				}()
+
+				err = server.Serve(listener)
+				stopped = true
+				if err == http.ErrServerClosed {
+					return nil
+				}
+				return err
+			},
+			WaitTimeout: time.Second * 2,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body := getBody(t, fixture.BaseURL()); body != "Hello world" {
+		t.Errorf("Should serve the Server from BaseURL(), but got '%s'", body)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !stopped {
+		t.Errorf("Should stop the Server during Teardown")
+	}
+}
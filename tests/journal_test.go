@@ -0,0 +1,82 @@
+package baloon_test
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestJournalOnlyDeletesFiles(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	// a directory, e.g. from BuildArguments "-o dir/", and a directory outside os.TempDir()
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "bin")
+	otherDir := filepath.Join(dir, "baloon_cover_123")
+	binary := filepath.Join(dir, "app")
+
+	for _, path := range []string{outputDir, otherDir} {
+		err := os.MkdirAll(filepath.Join(path, "keep"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := os.WriteFile(binary, []byte("binary"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a journal left by a test run that's no longer running
+	hash := fnv.New64a()
+	hash.Write([]byte(appRootPath))
+	journalDir := filepath.Join(os.TempDir(), "baloon", strconv.FormatUint(hash.Sum64(), 16))
+
+	err = os.MkdirAll(journalDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var journal []byte
+	for _, entry := range []map[string]string{{"file": outputDir}, {"dir": otherDir}, {"file": binary}} {
+		line, _ := json.Marshal(entry)
+		journal = append(append(journal, line...), '\n')
+	}
+
+	journalPath := filepath.Join(journalDir, "2147483646-test.journal")
+	err = os.WriteFile(journalPath, journal, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(journalPath)
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot:  appRootPath,
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(binary); !os.IsNotExist(err) {
+		t.Errorf("Should delete files left by earlier test runs")
+	}
+
+	for _, path := range []string{outputDir, otherDir} {
+		if _, err := os.Stat(filepath.Join(path, "keep")); err != nil {
+			t.Errorf("Should not delete directories that weren't created by baloon, but %s was deleted", path)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package baloon_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestPollutionCheck(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script: baloon.NewScript(`
+					CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+					CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT);
+					INSERT INTO customers (name) VALUES ('Alice');`),
+			},
+		},
+		PollutionChecks: []baloon.PollutionCheck{
+			{Connection: conn},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Clean", func(t *testing.T) {
+		fixture.UnitTestSetup(t)
+		defer fixture.UnitTestTeardown(t)
+
+		execSQL(t, conn, "INSERT INTO products (name) VALUES ('Tea'); DELETE FROM products;")
+	})
+
+	t.Run("Polluting", func(t *testing.T) {
+		fixture.UnitTestSetup(t)
+		defer fixture.UnitTestTeardown(t)
+
+		execSQL(t, conn, "INSERT INTO products (name) VALUES ('Tea');")
+		execSQL(t, conn, "UPDATE customers SET name = 'Bob';")
+	})
+
+	// shouldn't be blamed for the pollution left by the previous test
+	t.Run("AfterPolluting", func(t *testing.T) {
+		fixture.UnitTestSetup(t)
+		defer fixture.UnitTestTeardown(t)
+	})
+
+	err = fixture.Teardown()
+	if err == nil {
+		t.Fatal("Should return an error from Teardown when tests pollute the database")
+	}
+
+	expected := `TestPollutionCheck/Polluting: "customers" has different data to after Setup, ` +
+		`"products" has 1 rows, but had 0 rows after Setup`
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("Should report which tests changed which tables. Error was: %s", err.Error())
+	}
+
+	if strings.Contains(err.Error(), "TestPollutionCheck/Clean") || strings.Contains(err.Error(), "TestPollutionCheck/AfterPolluting") {
+		t.Errorf("Should only report tests that polluted the database. Error was: %s", err.Error())
+	}
+}
+
+func TestPollutionCheckWithSnapshot(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script: baloon.NewScript(`
+					CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+					INSERT INTO customers (name) VALUES ('Alice');`),
+			},
+		},
+		Snapshots: []baloon.Snapshot{
+			{Connection: baloon.DBConn{Driver: "sqlite3"}, Database: dbPath},
+		},
+		PollutionChecks: []baloon.PollutionCheck{
+			{Connection: conn},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the snapshot is restored before the second test, so it pollutes the database too
+	for _, name := range []string{"First", "Second"} {
+		t.Run(name, func(t *testing.T) {
+			fixture.UnitTestSetup(t)
+			defer fixture.UnitTestTeardown(t)
+
+			execSQL(t, conn, "UPDATE customers SET name = 'Bob';")
+		})
+	}
+
+	err = fixture.Teardown()
+	if err == nil {
+		t.Fatal("Should return an error from Teardown when tests pollute the database")
+	}
+
+	if !strings.Contains(err.Error(), "TestPollutionCheckWithSnapshot/First") || !strings.Contains(err.Error(), "TestPollutionCheckWithSnapshot/Second") {
+		t.Errorf("Should compare with the database after the snapshot is restored. Error was: %s", err.Error())
+	}
+}
@@ -0,0 +1,201 @@
+package baloon_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestParallel(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script: baloon.NewScript(`
+					CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+					INSERT INTO customers (name) VALUES ('Alice'), ('Bob');`),
+			},
+		},
+		Parallel: baloon.Parallel{
+			Size: 2,
+			Databases: []baloon.ParallelDatabase{
+				{Connection: conn, Database: dbPath},
+			},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// runs against each test's own copy of the database
+	fixture.AddUnitTestSetup(baloon.UnitTest{
+		DatabaseRoutines: []baloon.DB{
+			{
+				Connection: baloon.DBConn{Driver: "sqlite3", String: fmt.Sprintf("{{database %q}}", dbPath)},
+				Script:     baloon.NewScript("INSERT INTO customers (name) VALUES ('Carol');"),
+			},
+		},
+	})
+
+	t.Run("Group", func(t *testing.T) {
+		for i := 0; i < 6; i++ {
+			t.Run(fmt.Sprintf("Test%d", i), func(t *testing.T) {
+				t.Parallel()
+
+				lease := fixture.Acquire(t)
+
+				leaseConn, err := lease.DB(dbPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if leaseConn.String == dbPath {
+					t.Fatalf("Should connect to a copy of the database")
+				}
+
+				if count := countRows(t, leaseConn, "customers"); count != 3 {
+					t.Errorf("Should start with the database as it was after Setup, plus unit test setups, but got %d customers", count)
+				}
+
+				execSQL(t, leaseConn, "INSERT INTO customers (name) VALUES ('Dave');")
+			})
+		}
+	})
+
+	if count := countRows(t, conn, "customers"); count != 2 {
+		t.Errorf("Should not change the original database, but got %d customers", count)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copies, _ := filepath.Glob(dbPath + "_baloon_*")
+	if len(copies) > 0 {
+		t.Errorf("Should delete copies of the database during Teardown, but found %v", copies)
+	}
+
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("Should leave the original database, but got error: %s", err.Error())
+	}
+}
+
+func TestParallelApps(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);"),
+			},
+		},
+		Parallel: baloon.Parallel{
+			Size: 2,
+			Databases: []baloon.ParallelDatabase{
+				{Connection: conn, Database: dbPath},
+			},
+			Apps: true,
+		},
+		AppSetup: baloon.App{
+			RunArguments: []string{
+				"-port", "{{.Port}}",
+				"-message", fmt.Sprintf("{{database %q}}", dbPath),
+				"-ready_statement", "Running",
+			},
+			WaitForOutputLine: "Running",
+			WaitTimeout:       time.Second * 2,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body := getBody(t, fixture.BaseURL()); body != dbPath {
+		t.Errorf("Should run the App against the original database, but got '%s'", body)
+	}
+
+	var mutex sync.Mutex
+	baseURLs := map[string]bool{}
+
+	t.Run("Group", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			t.Run(fmt.Sprintf("Test%d", i), func(t *testing.T) {
+				t.Parallel()
+
+				lease := fixture.Acquire(t)
+
+				if lease.BaseURL() == fixture.BaseURL() {
+					t.Errorf("Should use a separate instance of the App")
+				}
+
+				if body := getBody(t, lease.BaseURL()); body != lease.Database(dbPath) {
+					t.Errorf("Should run the App against the Lease's copy of the database, but got '%s'", body)
+				}
+
+				mutex.Lock()
+				baseURLs[lease.BaseURL()] = true
+				mutex.Unlock()
+			})
+		}
+	})
+
+	if len(baseURLs) != 2 {
+		t.Errorf("Should start an instance of the App for each Lease, but got %d", len(baseURLs))
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParallelNotSet(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	_, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		Parallel: baloon.Parallel{
+			Databases: []baloon.ParallelDatabase{
+				{Connection: baloon.DBConn{Driver: "postgres"}, Database: "northwind"},
+			},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err == nil {
+		t.Errorf("Should return an error when Clone isn't set for Postgres")
+	} else if !strings.Contains(err.Error(), "Parallel.Databases[0].Clone has not been set") {
+		t.Errorf("Wrong error returned when Clone isn't set. Error was: %s", err.Error())
+	}
+}
@@ -0,0 +1,149 @@
+package baloon_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sironfoot/baloon"
+)
+
+// processAlive reports whether a process is running, treating zombies as dead
+// since they may not get reaped inside containers.
+func processAlive(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(data)[strings.LastIndex(string(data), ")")+1:])
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestTeardownKillsProcessTree(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			RunArguments: []string{
+				"-helper",
+				"-ready_statement", "Running",
+			},
+			WaitForOutputLine: "Running",
+			WaitTimeout:       time.Second * 2,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := regexp.MustCompile(`helper=(\d+)`).FindStringSubmatch(fixture.AppOutput(""))
+	if match == nil {
+		t.Fatalf("App didn't start a helper process. Output was: %s", fixture.AppOutput(""))
+	}
+	pid, _ := strconv.Atoi(match[1])
+
+	if !processAlive(pid) {
+		t.Fatalf("Helper process should be running")
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// give the kernel a moment to deliver the signal
+	for i := 0; i < 50 && processAlive(pid); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if processAlive(pid) {
+		t.Errorf("Should kill processes started by the App during Teardown")
+	}
+}
+
+func TestSetupCleansUpAfterKilledRun(t *testing.T) {
+	testBinary := buildRunMain(t)
+	dir := t.TempDir()
+	appBinary := filepath.Join(dir, "app")
+
+	// killed while the tests are running, so it can't clean up
+	cmd := exec.Command(testBinary, "-test.v")
+	cmd.Dir = "./testdata/runmain"
+	cmd.Env = append(os.Environ(), "RUNMAIN_BINARY="+appBinary, "RUNMAIN_WAIT=1", "RUNMAIN_HELPER=1")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid := 0
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if match := regexp.MustCompile(`helper=(\d+)`).FindStringSubmatch(scanner.Text()); match != nil {
+			pid, _ = strconv.Atoi(match[1])
+		}
+		if scanner.Text() == "Waiting" {
+			break
+		}
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	if pid == 0 {
+		t.Fatal("App didn't start a helper process")
+	}
+	defer syscall.Kill(pid, syscall.SIGKILL)
+
+	if !processAlive(pid) {
+		t.Fatal("Helper process should be left running")
+	}
+
+	if _, err := os.Stat(appBinary); err != nil {
+		t.Fatalf("Compiled App binary should be left behind, but got error: %s", err.Error())
+	}
+
+	// the next run cleans up during Setup
+	cmd = exec.Command(testBinary)
+	cmd.Dir = "./testdata/runmain"
+	cmd.Env = append(os.Environ(), "RUNMAIN_BINARY="+filepath.Join(dir, "app2"))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Error running tests: %s\n%s", err.Error(), output)
+	}
+
+	for i := 0; i < 50 && processAlive(pid); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if processAlive(pid) {
+		t.Errorf("Should kill processes left running by the killed test run")
+	}
+
+	if _, err := os.Stat(appBinary); !os.IsNotExist(err) {
+		t.Errorf("Should delete the compiled App binary left by the killed test run")
+	}
+}
@@ -0,0 +1,133 @@
+package baloon_test
+
+import (
+	"errors"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sironfoot/baloon"
+)
+
+func init() {
+	baloon.RegisterProfileFlag()
+}
+
+func TestProfiles(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	localConn := baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "local.db")}
+	ciConn := baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "ci.db")}
+
+	config := baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: localConn,
+				Script:     baloon.NewScript("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);"),
+			},
+		},
+		AppSetup: inProcessApp,
+		Profiles: map[string]baloon.FixtureConfig{
+			"ci": {
+				DatabaseSetups: []baloon.DB{
+					{Connection: baloon.DBConn{String: ciConn.String}},
+				},
+			},
+		},
+	}
+
+	// runs Setup, and returns which databases the DatabaseSetups ran against
+	setup := func(t *testing.T, config baloon.FixtureConfig) (local bool, ci bool) {
+		fixture, err := baloon.NewFixture(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fixture.Close()
+
+		err = fixture.Setup()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		local = countRows(t, localConn, "sqlite_master WHERE name = 'customers'") == 1
+		ci = countRows(t, ciConn, "sqlite_master WHERE name = 'customers'") == 1
+
+		execSQL(t, localConn, "DROP TABLE IF EXISTS customers;")
+		execSQL(t, ciConn, "DROP TABLE IF EXISTS customers;")
+
+		return local, ci
+	}
+
+	if local, ci := setup(t, config); !local || ci {
+		t.Errorf("Should use the base config when no profile is chosen")
+	}
+
+	t.Setenv(baloon.ProfileEnv, "ci")
+
+	if local, ci := setup(t, config); local || !ci {
+		t.Errorf("Should merge the profile chosen by %s over the base config", baloon.ProfileEnv)
+	}
+
+	if config.DatabaseSetups[0].Connection != localConn {
+		t.Errorf("Should not change the base config, but Connection was: %v", config.DatabaseSetups[0].Connection)
+	}
+
+	t.Setenv(baloon.ProfileEnv, "local")
+
+	_, err := baloon.NewFixture(config)
+
+	var configErr *baloon.ConfigError
+	if !errors.As(err, &configErr) || configErr.Field != "Profile" {
+		t.Errorf("Should return error for unknown profiles, but got: %v", err)
+	}
+
+	// the test flag takes precedence over the environment variable
+	flag.Set(baloon.ProfileFlag, "ci")
+	t.Cleanup(func() {
+		flag.Set(baloon.ProfileFlag, "")
+	})
+
+	if local, ci := setup(t, config); local || !ci {
+		t.Errorf("Should merge the profile chosen by -baloon.profile over the base config")
+	}
+
+	config.Profile = "ci"
+	config.Profiles["local"] = baloon.FixtureConfig{}
+	flag.Set(baloon.ProfileFlag, "local")
+
+	if local, ci := setup(t, config); local || !ci {
+		t.Errorf("Should merge the profile chosen by FixtureConfig.Profile over the base config")
+	}
+}
+
+func TestProfileSecrets(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "northwind.db")},
+				// fails with "no such table: base-secret ci-secret"
+				Script: baloon.NewScript("SELECT * FROM `base-secret ci-secret`;"),
+			},
+		},
+		AppSetup: inProcessApp,
+		Secrets:  []string{"base-secret"},
+		Profiles: map[string]baloon.FixtureConfig{
+			"ci": {Secrets: []string{"ci-secret"}},
+		},
+		Profile: "ci",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err == nil || !strings.Contains(err.Error(), "no such table: ***** *****") {
+		t.Errorf("Should mask the Secrets from both the base config and the profile, but got: %v", err)
+	}
+}
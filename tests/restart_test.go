@@ -0,0 +1,145 @@
+package baloon_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestRestartApp(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			RunArguments: []string{
+				"-port", "{{.Port}}",
+				"-ready_statement", "Running",
+			},
+			Env: []string{
+				"APP_MESSAGE=first",
+			},
+			WaitForOutputLine: "Running",
+			WaitTimeout:       time.Second * 2,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.StopApp("")
+	if err == nil {
+		t.Errorf("Should return an error if calling StopApp() before Setup()")
+	}
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseURL := fixture.BaseURL()
+	if body := getBody(t, baseURL); body != "first" {
+		t.Errorf("Should use App Env, but got '%s'", body)
+	}
+
+	err = fixture.StartApp(baloon.AppOptions{})
+	if err == nil {
+		t.Errorf("Should return an error if calling StartApp() while the App is running")
+	}
+
+	err = fixture.RestartApp(baloon.AppOptions{
+		Env: []string{"APP_MESSAGE=second"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fixture.BaseURL() != baseURL {
+		t.Errorf("Should keep the same URL after restarting, but got '%s'", fixture.BaseURL())
+	}
+
+	if body := getBody(t, baseURL); body != "second" {
+		t.Errorf("Should use changed Env after restarting, but got '%s'", body)
+	}
+
+	err = fixture.StopApp("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fixture.StartApp(baloon.AppOptions{
+		RunArguments: []string{
+			"-port", "{{.Port}}",
+			"-message", "third",
+			"-ready_statement", "Running",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body := getBody(t, baseURL); body != "third" {
+		t.Errorf("Should use changed RunArguments after starting, but got '%s'", body)
+	}
+
+	if count := strings.Count(fixture.AppOutput(""), "Running\n"); count != 3 {
+		t.Errorf("Should capture App output across restarts, but found ready line %d times", count)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBaseURLDuringRestart checks BaseURL can be called while another goroutine restarts
+// the App. Run with -race to check for data races.
+func TestBaseURLDuringRestart(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot:  appRootPath,
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 5; i++ {
+			err := fixture.RestartApp(baloon.AppOptions{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			if fixture.BaseURL() == "" {
+				t.Errorf("Should return the App's URL after restarting")
+			}
+			return
+		default:
+			fixture.BaseURL()
+			fixture.AppURL("")
+		}
+	}
+}
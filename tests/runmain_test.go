@@ -0,0 +1,157 @@
+package baloon_test
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// buildRunMain compiles the tests in testdata/runmain, which use RunMain.
+func buildRunMain(t *testing.T) string {
+	testBinary := filepath.Join(t.TempDir(), "runmain.test")
+
+	output, err := exec.Command("go", "test", "-c", "-o", testBinary, "./testdata/runmain").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Error building test binary: %s\n%s", err.Error(), output)
+	}
+
+	return testBinary
+}
+
+func TestRunMain(t *testing.T) {
+	testBinary := buildRunMain(t)
+	appBinary := filepath.Join(t.TempDir(), "app")
+
+	run := func(env ...string) (int, string) {
+		cmd := exec.Command(testBinary)
+		cmd.Dir = "./testdata/runmain"
+		cmd.Env = append(append(os.Environ(), "RUNMAIN_BINARY="+appBinary), env...)
+
+		output, err := cmd.CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), string(output)
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		return 0, string(output)
+	}
+
+	if code, output := run(); code != 0 {
+		t.Errorf("Should exit with 0 when tests pass, but got %d: %s", code, output)
+	}
+
+	if code, output := run("RUNMAIN_TEST_FAILS=1"); code == 0 {
+		t.Errorf("Should exit with non-zero when tests fail: %s", output)
+	}
+
+	code, output := run("RUNMAIN_SETUP_FAILS=1")
+	if code == 0 {
+		t.Errorf("Should exit with non-zero when Setup fails: %s", output)
+	} else if !strings.Contains(output, "baloon: Setup failed: Timeout waiting for program to start") {
+		t.Errorf("Should report why Setup failed: %s", output)
+	}
+
+	code, output = run("RUNMAIN_TEARDOWN_FAILS=1")
+	if code == 0 {
+		t.Errorf("Should exit with non-zero when Teardown fails: %s", output)
+	} else if !strings.Contains(output, "baloon: Teardown failed: Error running Database Teardown at index 0") {
+		t.Errorf("Should report why Teardown failed: %s", output)
+	}
+
+	if _, err := os.Stat(appBinary); !os.IsNotExist(err) {
+		t.Errorf("Should delete the compiled App binary")
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	// interrupted while the tests are running
+	cmd := exec.Command(testBinary, "-test.v")
+	cmd.Dir = "./testdata/runmain"
+	cmd.Env = append(append(os.Environ(), "RUNMAIN_BINARY="+appBinary), "RUNMAIN_WAIT=1")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if scanner.Text() == "Waiting" {
+			break
+		}
+	}
+
+	cmd.Process.Signal(syscall.SIGTERM)
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		if err == nil {
+			t.Errorf("Should exit with non-zero when interrupted")
+		}
+	case <-time.After(time.Second * 10):
+		cmd.Process.Kill()
+		t.Fatal("Should exit when interrupted")
+	}
+
+	if _, err := os.Stat(appBinary); !os.IsNotExist(err) {
+		t.Errorf("Should delete the compiled App binary when interrupted")
+	}
+
+	// interrupted while Setup is waiting for the App to start
+	cmd = exec.Command(testBinary)
+	cmd.Dir = "./testdata/runmain"
+	cmd.Env = append(append(os.Environ(), "RUNMAIN_BINARY="+appBinary), "RUNMAIN_SLOW_SETUP=1")
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for start := time.Now(); time.Since(start) < time.Second*30; time.Sleep(time.Millisecond * 50) {
+		if _, err := os.Stat(appBinary); err == nil {
+			break
+		}
+	}
+
+	// give the App time to start
+	time.Sleep(time.Millisecond * 500)
+	cmd.Process.Signal(syscall.SIGTERM)
+
+	exited = make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		if err == nil {
+			t.Errorf("Should exit with non-zero when interrupted during Setup")
+		}
+	case <-time.After(time.Second * 10):
+		cmd.Process.Kill()
+		t.Fatal("Should exit when interrupted during Setup, without waiting for it to finish")
+	}
+
+	if _, err := os.Stat(appBinary); !os.IsNotExist(err) {
+		t.Errorf("Should delete the compiled App binary when interrupted during Setup")
+	}
+}
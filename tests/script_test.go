@@ -1,9 +1,17 @@
 package baloon_test
 
 import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/sironfoot/baloon"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestNewScript(t *testing.T) {
@@ -31,3 +39,226 @@ func TestNewScriptPath(t *testing.T) {
 		t.Errorf("Should return the command we set '%s' but got '%s'", command, script.Command)
 	}
 }
+
+func TestNewScriptFS(t *testing.T) {
+	fsys := fstest.MapFS{}
+	pattern := "sql/*.sql"
+
+	script := baloon.NewScriptFS(fsys, pattern)
+	if script.Type != baloon.ScriptTypeFS {
+		t.Errorf("Should return Type as ScriptTypeFS but got %d", script.Type)
+	}
+
+	if script.Command != pattern {
+		t.Errorf("Should return the pattern we set '%s' but got '%s'", pattern, script.Command)
+	}
+}
+
+func TestScriptFS(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	conn := baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "northwind.db")}
+
+	seed := fstest.MapFS{
+		// run in name order, so the table is created before rows are inserted
+		"seed/02_customers.sql": {Data: []byte("INSERT INTO customers (name) VALUES ('Alice'), ('Bob');")},
+		"seed/01_schema.sql":    {Data: []byte("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);")},
+		"orders.sql":            {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY);")},
+	}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Scripts: []baloon.Script{
+					baloon.NewScriptFS(nil, "seed/*.sql"),
+					baloon.NewScriptFS(fstest.MapFS{"orders.sql": seed["orders.sql"]}, "*.sql"),
+				},
+			},
+		},
+		AppSetup: inProcessApp,
+		ScriptFS: seed,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countRows(t, conn, "customers"); count != 2 {
+		t.Errorf("Should run scripts from FixtureConfig.ScriptFS in order, but customers has %d rows", count)
+	}
+
+	if count := countRows(t, conn, "sqlite_master WHERE name = 'orders'"); count != 1 {
+		t.Errorf("Should run scripts from the FS passed to NewScriptFS")
+	}
+}
+
+func TestScriptFSProblems(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	conn := baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "northwind.db")}
+
+	_, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Scripts: []baloon.Script{
+					baloon.NewScriptFS(nil, "seed/*.sql"),
+					baloon.NewScriptFS(fstest.MapFS{}, "seed/*.sql"),
+				},
+			},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	var multiErr *baloon.MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+		t.Fatalf("Should return a problem for each script, but got: %v", err)
+	}
+
+	expected := []string{
+		"DatabaseSetups[0].Scripts[0] has no FS, pass one to NewScriptFS or set FixtureConfig.ScriptFS",
+		"DatabaseSetups[0].Scripts[1] pattern \"seed/*.sql\" doesn't match any files",
+	}
+
+	for i, message := range expected {
+		if multiErr.Errors[i].Error() != message {
+			t.Errorf("Expected error '%s', but was: %s", message, multiErr.Errors[i].Error())
+		}
+	}
+}
+
+func TestNewCreateDatabaseScript(t *testing.T) {
+	name := "northwind"
+
+	script := baloon.NewCreateDatabaseScript(name)
+	if script.Type != baloon.ScriptTypeCreateDatabase {
+		t.Errorf("Should return Type as ScriptTypeCreateDatabase but got %d", script.Type)
+	}
+
+	if script.Command != name {
+		t.Errorf("Should return the database name we set '%s' but got '%s'", name, script.Command)
+	}
+}
+
+func TestNewDropDatabaseScript(t *testing.T) {
+	name := "northwind"
+
+	script := baloon.NewDropDatabaseScript(name)
+	if script.Type != baloon.ScriptTypeDropDatabase {
+		t.Errorf("Should return Type as ScriptTypeDropDatabase but got %d", script.Type)
+	}
+
+	if script.Command != name {
+		t.Errorf("Should return the database name we set '%s' but got '%s'", name, script.Command)
+	}
+}
+
+func TestScriptTemplate(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	conn := baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "northwind.db")}
+	t.Setenv("SEED_COUNTRY", "NZ")
+
+	seed := fstest.MapFS{
+		"users.sql": {Data: []byte(`INSERT INTO users VALUES ('{{uuid}}', '{{random 8}}', '{{bcrypt "secret"}}', '{{env "SEED_COUNTRY"}}', '{{now.Format "2006-01-02"}}');`)},
+	}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Scripts: []baloon.Script{
+					baloon.NewScript(`CREATE TABLE {{var "table"}} (id TEXT, name TEXT, password TEXT, country TEXT, created TEXT);`).AsTemplate(),
+					baloon.NewScriptFS(seed, "users.sql").AsTemplate(),
+					// not templated, so left as it is
+					baloon.NewScript(`INSERT INTO users (name) VALUES ('{{uuid}}');`),
+				},
+			},
+		},
+		AppSetup:  inProcessApp,
+		Variables: map[string]string{"table": "users"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(conn.Driver, conn.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var id, name, password, country, created string
+	err = db.QueryRow("SELECT id, name, password, country, created FROM users WHERE id IS NOT NULL").Scan(&id, &name, &password, &country, &created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("Should insert a UUID, but got: %s", id)
+	}
+
+	if !regexp.MustCompile(`^[a-z0-9]{8}$`).MatchString(name) {
+		t.Errorf("Should insert a random string, but got: %s", name)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(password), []byte("secret")) != nil {
+		t.Errorf("Should insert a bcrypt hash, but got: %s", password)
+	}
+
+	if country != "NZ" {
+		t.Errorf("Should insert environment variables, but got: %s", country)
+	}
+
+	if created != time.Now().Format("2006-01-02") {
+		t.Errorf("Should insert the current time, but got: %s", created)
+	}
+
+	if count := countRows(t, conn, "users WHERE name = '{{uuid}}'"); count != 1 {
+		t.Errorf("Should only execute Scripts as templates when asked")
+	}
+}
+
+func TestScriptTemplateError(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	conn := baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "northwind.db")}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewScriptFS(fstest.MapFS{"seed.sql": {Data: []byte("SELECT 1;\nSELECT '{{env \"BALOON_MISSING_VAR\"}}';")}}, "seed.sql").AsTemplate(),
+			},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err == nil {
+		t.Fatal("Should return an error when a template fails")
+	}
+
+	if !strings.Contains(err.Error(), "seed.sql:2") || !strings.Contains(err.Error(), "Environment variable \"BALOON_MISSING_VAR\" has not been set") {
+		t.Errorf("Should say where the template failed. Error was: %s", err.Error())
+	}
+}
@@ -0,0 +1,122 @@
+package baloon_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/sironfoot/baloon"
+)
+
+var inProcessApp = baloon.App{
+	InProcess: true,
+	Handler:   helloHandler,
+}
+
+func execSQL(t *testing.T, conn baloon.DBConn, command string) {
+	db, err := sql.Open(conn.Driver, conn.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countRows(t *testing.T, conn baloon.DBConn, table string) int {
+	db, err := sql.Open(conn.Driver, conn.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestSnapshot(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Scripts: []baloon.Script{
+					baloon.NewScript("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);"),
+					baloon.NewScript("INSERT INTO customers (name) VALUES ('Alice'), ('Bob');"),
+				},
+			},
+		},
+		Snapshots: []baloon.Snapshot{
+			{Connection: conn, Database: dbPath},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture.UnitTestSetup(t)
+	execSQL(t, conn, "INSERT INTO customers (name) VALUES ('Carol');")
+
+	if count := countRows(t, conn, "customers"); count != 3 {
+		t.Fatalf("Expected 3 customers but got %d", count)
+	}
+
+	fixture.UnitTestSetup(t)
+
+	if count := countRows(t, conn, "customers"); count != 2 {
+		t.Errorf("Should restore the database from the snapshot, but got %d customers", count)
+	}
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches, _ := filepath.Glob(dbPath + "_*")
+	if len(matches) > 0 {
+		t.Errorf("Should delete the snapshot during Teardown, but found %v", matches)
+	}
+
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("Should keep the database itself, but got: %s", err.Error())
+	}
+}
+
+func TestSnapshotUnsupported(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	_, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		Snapshots: []baloon.Snapshot{
+			{Connection: baloon.DBConn{Driver: "mysql"}, Database: "northwind"},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err == nil || err.Error() != "Snapshots[0]: MySQL databases can't be snapshotted" {
+		t.Errorf("Should return error for databases that can't be snapshotted, but got: %v", err)
+	}
+}
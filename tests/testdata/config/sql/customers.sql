@@ -0,0 +1 @@
+INSERT INTO customers (name) VALUES ('Alice'), ('Bob');
@@ -0,0 +1,77 @@
+package runmain_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sironfoot/baloon"
+)
+
+var fixture baloon.Fixture
+
+// Run by TestRunMain, with environment variables choosing what to test.
+func TestMain(m *testing.M) {
+	appRootPath, _ := filepath.Abs("../../app/")
+
+	config := baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		AppSetup: baloon.App{
+			BuildArguments: []string{
+				"-o", os.Getenv("RUNMAIN_BINARY"),
+			},
+			RunArguments: []string{
+				"-ready_statement", "Running",
+			},
+			WaitForOutputLine: "Running",
+			WaitTimeout:       time.Second * 10,
+		},
+	}
+
+	if os.Getenv("RUNMAIN_HELPER") != "" {
+		config.AppSetup.RunArguments = append(config.AppSetup.RunArguments, "-helper")
+	}
+
+	if os.Getenv("RUNMAIN_SETUP_FAILS") != "" {
+		config.AppSetup.WaitForOutputLine = "Never"
+		config.AppSetup.WaitTimeout = time.Millisecond * 100
+	}
+
+	if os.Getenv("RUNMAIN_SLOW_SETUP") != "" {
+		config.AppSetup.WaitForOutputLine = "Never"
+		config.AppSetup.WaitTimeout = time.Minute
+	}
+
+	if os.Getenv("RUNMAIN_TEARDOWN_FAILS") != "" {
+		config.DatabaseTeardowns = []baloon.DB{
+			{
+				Connection: baloon.DBConn{Driver: "sqlite3", String: filepath.Join(os.TempDir(), "baloon-missing", "northwind.db")},
+				Script:     baloon.NewScript("DROP TABLE customers;"),
+			},
+		}
+	}
+
+	var err error
+	fixture, err = baloon.NewFixture(config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	os.Exit(fixture.RunMain(m))
+}
+
+func TestSomething(t *testing.T) {
+	if os.Getenv("RUNMAIN_TEST_FAILS") != "" {
+		t.Fatal("Failing test")
+	}
+
+	if os.Getenv("RUNMAIN_WAIT") != "" {
+		fmt.Println(fixture.AppOutput(""))
+		fmt.Println("Waiting")
+		time.Sleep(time.Minute)
+	}
+}
@@ -0,0 +1,161 @@
+package baloon_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestChangeTracking(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	dbPath := filepath.Join(t.TempDir(), "northwind.db")
+	conn := baloon.DBConn{Driver: "sqlite3", String: dbPath + "?_foreign_keys=on"}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script: baloon.NewScript(`
+					CREATE TABLE customers (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT);
+					CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, customer_id INTEGER REFERENCES customers (id));
+					CREATE TABLE products (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT);
+					INSERT INTO customers (name) VALUES ('Alice'), ('Bob');
+					INSERT INTO orders (customer_id) VALUES (1), (2);
+					INSERT INTO products (name) VALUES ('Tea');`),
+			},
+		},
+		ChangeTracking: []baloon.ChangeTracking{
+			{Connection: conn},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changing customers means orders must be restored as well
+	fixture.UnitTestSetup(t)
+	execSQL(t, conn, "DELETE FROM orders WHERE customer_id = 2; DELETE FROM customers WHERE id = 2;")
+	execSQL(t, conn, "INSERT INTO customers (name) VALUES ('Carol');")
+	fixture.UnitTestTeardown(t)
+
+	if count := countRows(t, conn, "customers"); count != 2 {
+		t.Errorf("Should restore changed tables, but got %d customers", count)
+	}
+
+	if count := countRows(t, conn, "orders"); count != 2 {
+		t.Errorf("Should restore tables referencing changed tables, but got %d orders", count)
+	}
+
+	if count := countRows(t, conn, "baloon_changed_tables"); count != 0 {
+		t.Errorf("Should reset change tracking after restoring, but got %d changed tables", count)
+	}
+
+	// auto-increment counters should be back to how they were
+	fixture.UnitTestSetup(t)
+	execSQL(t, conn, "INSERT INTO customers (name) VALUES ('Dave');")
+	if count := countRows(t, conn, "customers WHERE id = 3"); count != 1 {
+		t.Errorf("Should restore auto-increment counters")
+	}
+	fixture.UnitTestTeardown(t)
+
+	err = fixture.Teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countRows(t, conn, "sqlite_master WHERE name LIKE 'baloon%'"); count != 0 {
+		t.Errorf("Should remove change tracking during Teardown, but found %d tables/triggers", count)
+	}
+}
+
+func TestChangeTrackingLeftovers(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	conn := baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "northwind.db")}
+
+	// tracking left behind by a test run that was killed before Teardown
+	execSQL(t, conn, `
+		CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE baloon_changed_tables (name TEXT PRIMARY KEY);
+		CREATE TABLE baloon_sequences (name TEXT, seq INTEGER);
+		CREATE TABLE baloon_seed_customers AS SELECT * FROM customers;
+		CREATE TRIGGER baloon_track_customers_insert AFTER INSERT ON customers
+			BEGIN INSERT OR IGNORE INTO baloon_changed_tables (name) VALUES ('customers'); END;`)
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("INSERT INTO customers (name) VALUES ('Alice'), ('Bob');"),
+			},
+		},
+		ChangeTracking: []baloon.ChangeTracking{
+			{Connection: conn},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatalf("Should replace tracking left behind by earlier runs, but got error: %s", err.Error())
+	}
+
+	fixture.UnitTestSetup(t)
+	execSQL(t, conn, "DELETE FROM customers;")
+	fixture.UnitTestTeardown(t)
+
+	if count := countRows(t, conn, "customers"); count != 2 {
+		t.Errorf("Should restore tables to how they were after Setup, but got %d customers", count)
+	}
+}
+
+func TestChangeTrackingInstallError(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+	conn := baloon.DBConn{Driver: "sqlite3", String: filepath.Join(t.TempDir(), "northwind.db")}
+
+	// the leftover seed copy can't be dropped with DROP TABLE, so tracking can't be installed
+	execSQL(t, conn, `
+		CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE VIEW baloon_seed_customers AS SELECT * FROM customers;`)
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("INSERT INTO customers (name) VALUES ('Alice'), ('Bob');"),
+			},
+		},
+		ChangeTracking: []baloon.ChangeTracking{
+			{Connection: conn},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	// rather than cleaning every table, and the seed data with them, after each test
+	err = fixture.Setup()
+	if err == nil || !strings.Contains(err.Error(), "Error setting up Change Tracking at index 0") {
+		t.Errorf("Should return error when change tracking can't be installed, but got: %v", err)
+	}
+}
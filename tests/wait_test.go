@@ -0,0 +1,91 @@
+package baloon_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sironfoot/baloon"
+)
+
+func TestDatabaseWait(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	// the database can't be opened until its directory exists, like a server that isn't up yet
+	dir := filepath.Join(t.TempDir(), "data")
+	conn := baloon.DBConn{
+		Driver:      "sqlite3",
+		String:      filepath.Join(dir, "northwind.db"),
+		WaitTimeout: time.Second * 5,
+	}
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: conn,
+				Script:     baloon.NewScript("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);"),
+			},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	go func() {
+		time.Sleep(time.Millisecond * 300)
+		os.Mkdir(dir, 0755)
+	}()
+
+	err = fixture.Setup()
+	if err != nil {
+		t.Fatalf("Should wait for the database to be ready, but got error: %s", err.Error())
+	}
+
+	if count := countRows(t, conn, "sqlite_master WHERE name = 'customers'"); count != 1 {
+		t.Errorf("Should run the scripts once the database is ready")
+	}
+}
+
+func TestDatabaseWaitTimeout(t *testing.T) {
+	appRootPath, _ := filepath.Abs("./app/")
+
+	fixture, err := baloon.NewFixture(baloon.FixtureConfig{
+		AppRoot: appRootPath,
+		DatabaseSetups: []baloon.DB{
+			{
+				Connection: baloon.DBConn{
+					Driver:      "sqlite3",
+					String:      filepath.Join(t.TempDir(), "missing", "northwind.db"),
+					WaitTimeout: time.Millisecond * 300,
+				},
+				Script: baloon.NewScript("CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);"),
+			},
+		},
+		AppSetup: inProcessApp,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fixture.Close()
+
+	start := time.Now()
+	err = fixture.Setup()
+	if err == nil {
+		t.Fatal("Should return an error when the database never becomes ready")
+	}
+
+	if !strings.Contains(err.Error(), "Database wasn't ready after 300ms: unable to open database file") {
+		t.Errorf("Should return the last connection error. Error was: %s", err.Error())
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Millisecond*200 || elapsed > time.Second*2 {
+		t.Errorf("Should retry until WaitTimeout, but took %s", elapsed)
+	}
+}
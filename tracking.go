@@ -0,0 +1,468 @@
+package baloon
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ChangeTracking represents a database where baloon tracks which tables are changed during
+// each unit test, so that UnitTestTeardown only has to restore those tables to how they were
+// after Setup. Change tracking uses triggers, and supports Postgres and SQLite. For other
+// databases, all tables are cleaned instead, and Setup returns an error if tracking can't
+// be installed in a database that supports it.
+type ChangeTracking struct {
+	// Connection is the database to track changes in.
+	Connection DBConn
+
+	// Exclude is a list of table names to not track or restore.
+	// Names can include the schema.
+	Exclude []string
+}
+
+// trackingDialect is implemented by dialects that can track changed tables with triggers.
+type trackingDialect interface {
+	cleanDialect
+
+	// installTracking copies the data in tables, and adds triggers to track changes to them.
+	installTracking(db *sql.DB, tables []table) error
+
+	// changedTables returns the names of tables that have changed since tracking was reset.
+	changedTables(db *sql.DB) ([]table, error)
+
+	// restoreTables replaces the data in tables with the copies made when tracking was
+	// installed, and resets tracking. Tables are ordered so that tables come before any
+	// tables they reference.
+	restoreTables(db *sql.DB, tables []table) error
+
+	// uninstallTracking removes tracking triggers and copied data.
+	uninstallTracking(db *sql.DB, tables []table) error
+}
+
+// changeTracker tracks changes to a database during each unit test.
+type changeTracker struct {
+	tracking    ChangeTracking
+	dialect     trackingDialect
+	tables      []table
+	foreignKeys []foreignKey
+	installed   bool
+}
+
+func newChangeTracker(tracking ChangeTracking) (*changeTracker, error) {
+	d, err := dialectFor(tracking.Connection.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker := &changeTracker{tracking: tracking}
+	if trackingDialect, ok := d.(trackingDialect); ok {
+		tracker.dialect = trackingDialect
+	}
+
+	return tracker, nil
+}
+
+// install starts tracking changes, if the database supports it.
+func (tracker *changeTracker) install() error {
+	if tracker.dialect == nil {
+		return nil
+	}
+
+	db, err := tracker.tracking.Connection.open()
+	if err != nil {
+		return fmt.Errorf("Error connecting to database: %s", err.Error())
+	}
+	defer db.Close()
+
+	allTables, err := tracker.dialect.tables(db)
+	if err != nil {
+		return fmt.Errorf("Error getting tables: %s", err.Error())
+	}
+	tracker.tables = excludeTables(allTables, tracker.tracking.Exclude)
+
+	tracker.foreignKeys, err = tracker.dialect.foreignKeys(db)
+	if err != nil {
+		return fmt.Errorf("Error getting foreign keys: %s", err.Error())
+	}
+
+	// remove anything left behind by an earlier test run that was killed
+	err = tracker.dialect.uninstallTracking(db, tracker.tables)
+	if err != nil {
+		return fmt.Errorf("Error removing change tracking left by an earlier test run: %s", err.Error())
+	}
+
+	err = tracker.dialect.installTracking(db, tracker.tables)
+	if err != nil {
+		tracker.dialect.uninstallTracking(db, tracker.tables)
+		return fmt.Errorf("Error installing change tracking: %s", err.Error())
+	}
+
+	tracker.installed = true
+	return nil
+}
+
+// restore puts any tables changed since the last restore back to how they were after Setup.
+// If changes can't be tracked, all tables are restored, or cleaned if the database doesn't
+// support change tracking.
+func (tracker *changeTracker) restore() error {
+	db, err := tracker.tracking.Connection.open()
+	if err != nil {
+		return fmt.Errorf("Error connecting to database: %s", err.Error())
+	}
+	defer db.Close()
+
+	if !tracker.installed {
+		err := cleanTables(db, tracker.tracking.Connection.Driver, tracker.tracking.Exclude)
+		if err != nil {
+			return fmt.Errorf("Error cleaning tables: %s", err.Error())
+		}
+		return nil
+	}
+
+	tables := tracker.tables
+
+	changed, err := tracker.dialect.changedTables(db)
+	if err == nil {
+		tables = referencingTables(changed, tracker.tables, tracker.foreignKeys)
+	}
+
+	if len(tables) == 0 {
+		return nil
+	}
+
+	err = tracker.dialect.restoreTables(db, orderTables(tables, tracker.foreignKeys))
+	if err != nil {
+		return fmt.Errorf("Error restoring changed tables: %s", err.Error())
+	}
+
+	return nil
+}
+
+// uninstall stops tracking changes.
+func (tracker *changeTracker) uninstall() error {
+	if !tracker.installed {
+		return nil
+	}
+
+	db, err := tracker.tracking.Connection.open()
+	if err != nil {
+		return fmt.Errorf("Error connecting to database: %s", err.Error())
+	}
+	defer db.Close()
+
+	err = tracker.dialect.uninstallTracking(db, tracker.tables)
+	if err != nil {
+		return fmt.Errorf("Error removing change tracking: %s", err.Error())
+	}
+
+	tracker.installed = false
+	return nil
+}
+
+// excludeTables returns tables, minus any that match the names in exclude.
+func excludeTables(tables []table, exclude []string) []table {
+	var included []table
+
+	for _, t := range tables {
+		excluded := false
+		for _, name := range exclude {
+			if t.matches(name) {
+				excluded = true
+				break
+			}
+		}
+
+		if !excluded {
+			included = append(included, t)
+		}
+	}
+
+	return included
+}
+
+// referencingTables returns the changed tables, plus any tables that reference them either
+// directly or indirectly, as those rows need deleting before the changed tables can be
+// restored. Only tables in 'tables' are returned.
+func referencingTables(changed []table, tables []table, foreignKeys []foreignKey) []table {
+	tracked := map[table]bool{}
+	for _, t := range tables {
+		tracked[t] = true
+	}
+
+	found := map[table]bool{}
+	var pending []table
+
+	for _, t := range changed {
+		if tracked[t] && !found[t] {
+			found[t] = true
+			pending = append(pending, t)
+		}
+	}
+
+	for len(pending) > 0 {
+		t := pending[0]
+		pending = pending[1:]
+
+		for _, fk := range foreignKeys {
+			if fk.to == t && tracked[fk.from] && !found[fk.from] {
+				found[fk.from] = true
+				pending = append(pending, fk.from)
+			}
+		}
+	}
+
+	// keep the original order of tables
+	var result []table
+	for _, t := range tables {
+		if found[t] {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// tableNames returns the names of tables, including any schema, as SQL string literals.
+func tableNames(tables []table) []string {
+	var names []string
+	for _, t := range tables {
+		name := t.name
+		if t.schema != "" {
+			name = t.schema + "." + t.name
+		}
+		names = append(names, quoteString(name))
+	}
+	return names
+}
+
+const postgresTrackingSchema = "baloon_tracking"
+
+func (d postgresDialect) seedTable(t table) string {
+	return d.quote(postgresTrackingSchema) + "." + d.quote("seed_"+t.schema+"."+t.name)
+}
+
+func (d postgresDialect) installTracking(db *sql.DB, tables []table) error {
+	schema := d.quote(postgresTrackingSchema)
+
+	commands := []string{
+		"CREATE SCHEMA IF NOT EXISTS " + schema + ";",
+		"CREATE TABLE IF NOT EXISTS " + schema + ".changed_tables (name text PRIMARY KEY);",
+		"CREATE TABLE IF NOT EXISTS " + schema + ".sequences (table_name text, sequence_name text, last_value bigint, is_called boolean);",
+		`CREATE OR REPLACE FUNCTION ` + schema + `.track_change() RETURNS trigger AS $$
+		BEGIN
+			INSERT INTO ` + schema + `.changed_tables (name)
+			VALUES (TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME)
+			ON CONFLICT DO NOTHING;
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`,
+	}
+
+	for _, t := range tables {
+		commands = append(commands,
+			"CREATE TABLE "+d.seedTable(t)+" AS SELECT * FROM "+t.quoted(d)+";",
+			"CREATE TRIGGER baloon_track_change AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON "+t.quoted(d)+
+				" FOR EACH STATEMENT EXECUTE PROCEDURE "+schema+".track_change();")
+	}
+
+	err := execCommands(db, commands)
+	if err != nil {
+		return err
+	}
+
+	// remember the current value of sequences used by tables, e.g. for serial columns
+	for _, t := range tables {
+		rows, err := db.Query(`
+			SELECT quote_ident(ns.nspname) || '.' || quote_ident(seq.relname)
+			FROM pg_class seq
+			JOIN pg_namespace ns ON ns.oid = seq.relnamespace
+			JOIN pg_depend dep ON dep.objid = seq.oid AND dep.deptype IN ('a', 'i')
+			WHERE seq.relkind = 'S' AND dep.refobjid = $1::regclass`, t.quoted(d))
+		if err != nil {
+			return err
+		}
+
+		var sequences []string
+		for rows.Next() {
+			var sequence string
+			err := rows.Scan(&sequence)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			sequences = append(sequences, sequence)
+		}
+		rows.Close()
+
+		for _, sequence := range sequences {
+			_, err := db.Exec("INSERT INTO "+schema+".sequences "+
+				"SELECT $1, $2, last_value, is_called FROM "+sequence+";", t.schema+"."+t.name, sequence)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d postgresDialect) changedTables(db *sql.DB) ([]table, error) {
+	rows, err := db.Query("SELECT name FROM " + d.quote(postgresTrackingSchema) + ".changed_tables;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []table
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+
+		parts := strings.SplitN(name, ".", 2)
+		tables = append(tables, table{schema: parts[0], name: parts[1]})
+	}
+
+	return tables, rows.Err()
+}
+
+func (d postgresDialect) restoreTables(db *sql.DB, tables []table) error {
+	var commands []string
+
+	for _, t := range tables {
+		commands = append(commands, "DELETE FROM "+t.quoted(d)+";")
+	}
+
+	for i := len(tables) - 1; i >= 0; i-- {
+		commands = append(commands, "INSERT INTO "+tables[i].quoted(d)+
+			" OVERRIDING SYSTEM VALUE SELECT * FROM "+d.seedTable(tables[i])+";")
+	}
+
+	schema := d.quote(postgresTrackingSchema)
+	commands = append(commands,
+		"SELECT setval(sequence_name, last_value, is_called) FROM "+schema+".sequences "+
+			"WHERE table_name IN ("+strings.Join(tableNames(tables), ", ")+");",
+		"DELETE FROM "+schema+".changed_tables;")
+
+	return execCommands(db, commands)
+}
+
+func (d postgresDialect) uninstallTracking(db *sql.DB, tables []table) error {
+	var commands []string
+
+	for _, t := range tables {
+		commands = append(commands, "DROP TRIGGER IF EXISTS baloon_track_change ON "+t.quoted(d)+";")
+	}
+	commands = append(commands, "DROP SCHEMA IF EXISTS "+d.quote(postgresTrackingSchema)+" CASCADE;")
+
+	return execCommands(db, commands)
+}
+
+func (d sqliteDialect) seedTable(t table) string {
+	return d.quote("baloon_seed_" + t.name)
+}
+
+func (d sqliteDialect) trackingTriggers(t table) []string {
+	var triggers []string
+	for _, event := range []string{"insert", "update", "delete"} {
+		triggers = append(triggers, d.quote("baloon_track_"+t.name+"_"+event))
+	}
+	return triggers
+}
+
+func (d sqliteDialect) installTracking(db *sql.DB, tables []table) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS baloon_changed_tables (name TEXT PRIMARY KEY);",
+		"CREATE TABLE IF NOT EXISTS baloon_sequences (name TEXT, seq INTEGER);",
+	}
+
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_sequence'").Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		commands = append(commands, "INSERT INTO baloon_sequences SELECT name, seq FROM sqlite_sequence;")
+	}
+
+	for _, t := range tables {
+		commands = append(commands, "CREATE TABLE "+d.seedTable(t)+" AS SELECT * FROM "+t.quoted(d)+";")
+
+		for i, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+			commands = append(commands, "CREATE TRIGGER "+d.trackingTriggers(t)[i]+
+				" AFTER "+event+" ON "+t.quoted(d)+
+				" BEGIN INSERT OR IGNORE INTO baloon_changed_tables (name) VALUES ("+quoteString(t.name)+"); END;")
+		}
+	}
+
+	return execCommands(db, commands)
+}
+
+func (sqliteDialect) changedTables(db *sql.DB) ([]table, error) {
+	return queryTables(db, "SELECT '', name FROM baloon_changed_tables;")
+}
+
+func (d sqliteDialect) restoreTables(db *sql.DB, tables []table) error {
+	var commands []string
+
+	for _, t := range tables {
+		commands = append(commands, "DELETE FROM "+t.quoted(d)+";")
+	}
+
+	for i := len(tables) - 1; i >= 0; i-- {
+		commands = append(commands, "INSERT INTO "+tables[i].quoted(d)+" SELECT * FROM "+d.seedTable(tables[i])+";")
+	}
+
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_sequence'").Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		names := strings.Join(tableNames(tables), ", ")
+		commands = append(commands,
+			"DELETE FROM sqlite_sequence WHERE name IN ("+names+");",
+			"INSERT INTO sqlite_sequence SELECT name, seq FROM baloon_sequences WHERE name IN ("+names+");")
+	}
+
+	commands = append(commands, "DELETE FROM baloon_changed_tables;")
+
+	return execCommands(db, commands)
+}
+
+func (d sqliteDialect) uninstallTracking(db *sql.DB, tables []table) error {
+	var commands []string
+
+	for _, t := range tables {
+		for _, trigger := range d.trackingTriggers(t) {
+			commands = append(commands, "DROP TRIGGER IF EXISTS "+trigger+";")
+		}
+		commands = append(commands, "DROP TABLE IF EXISTS "+d.seedTable(t)+";")
+	}
+
+	commands = append(commands,
+		"DROP TABLE IF EXISTS baloon_changed_tables;",
+		"DROP TABLE IF EXISTS baloon_sequences;")
+
+	return execCommands(db, commands)
+}
+
+// execCommands runs each command in turn within a transaction.
+func execCommands(db *sql.DB, commands []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, command := range commands {
+		_, err := tx.Exec(command)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,193 @@
+package baloon
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// problems collects everything wrong with a FixtureConfig, so NewFixture can report it all
+// at once rather than one problem per run.
+type problems struct {
+	errors []error
+}
+
+// add records a problem, if err isn't nil.
+func (problems *problems) add(err error) {
+	if err == nil {
+		return
+	}
+
+	// keep problems from nested checks flat
+	if multiErr, ok := err.(*MultiError); ok {
+		problems.errors = append(problems.errors, multiErr.Errors...)
+		return
+	}
+
+	problems.errors = append(problems.errors, err)
+}
+
+// addf records a ConfigError for a setting.
+func (problems *problems) addf(field string, format string, args ...interface{}) {
+	problems.add(newConfigError(field, format, args...))
+}
+
+// err returns a MultiError with the problems, or nil if there weren't any.
+func (problems *problems) err() error {
+	if len(problems.errors) == 0 {
+		return nil
+	}
+
+	return &MultiError{Errors: problems.errors}
+}
+
+// buildFlagsWithValues are the "go build" flags that take a value, which can be passed as
+// the next argument.
+var buildFlagsWithValues = map[string]bool{
+	"-o":          true,
+	"-p":          true,
+	"-asmflags":   true,
+	"-buildmode":  true,
+	"-compiler":   true,
+	"-coverpkg":   true,
+	"-covermode":  true,
+	"-gcflags":    true,
+	"-gccgoflags": true,
+	"-ldflags":    true,
+	"-mod":        true,
+	"-modfile":    true,
+	"-overlay":    true,
+	"-pgo":        true,
+	"-pkgdir":     true,
+	"-tags":       true,
+	"-toolexec":   true,
+}
+
+// checkBuildArguments checks that "go build" flags that take a value have one.
+func checkBuildArguments(app App, field string, problems *problems) {
+	args := app.BuildArguments
+
+	for i, arg := range args {
+		name, value, hasValue := splitBuildFlag(arg)
+		if !buildFlagsWithValues[name] {
+			continue
+		}
+
+		if hasValue {
+			if value == "" {
+				problems.addf(field+".BuildArguments", "%s.BuildArguments \"%s\" is missing a value", field, arg)
+			}
+			continue
+		}
+
+		if i+1 == len(args) || (name == "-o" && strings.HasPrefix(args[i+1], "-")) {
+			problems.addf(field+".BuildArguments", "%s.BuildArguments \"%s\" is missing a value", field, arg)
+		}
+	}
+}
+
+// checkGoToolchain checks the go command can be found to build the Apps that aren't
+// run InProcess.
+func checkGoToolchain(apps []App, fields []string, problems *problems) {
+	for i, app := range apps {
+		if app.InProcess {
+			continue
+		}
+
+		_, err := exec.LookPath("go")
+		if err != nil {
+			problems.addf(fields[i], "The go command is needed to build %s, but couldn't be found: %s", fields[i], err.Error())
+		}
+
+		return
+	}
+}
+
+// checkConnection checks the database/sql driver for a connection has been registered,
+// i.e. the driver's package has been imported, and that its connection string can be built.
+func checkConnection(conn DBConn, field string) error {
+	driverField := field + ".Driver"
+
+	if conn.Driver == "" {
+		return newConfigError(driverField, "%s has not been set", driverField)
+	}
+
+	registered := false
+	drivers := sql.Drivers()
+	for _, driver := range drivers {
+		if driver == conn.Driver {
+			registered = true
+		}
+	}
+
+	if !registered {
+		sort.Strings(drivers)
+		return newConfigError(driverField, "%s \"%s\" has not been registered, check the driver's package has been imported. Registered drivers are: %s",
+			driverField, conn.Driver, strings.Join(drivers, ", "))
+	}
+
+	_, err := conn.DSN()
+	if err != nil {
+		return newConfigError(field, "%s: %s", field, err.Error())
+	}
+
+	return nil
+}
+
+// checkDB checks a database setup or teardown's driver and scripts.
+func checkDB(db DB, field string, appRoot string, problems *problems) {
+	problems.add(checkConnection(db.Connection, field+".Connection"))
+
+	// the single Script is optional
+	if db.Script.Type != 0 || db.Script.Command != "" {
+		checkScript(db.Script, db.Connection, field+".Script", appRoot, problems)
+	}
+
+	for i, script := range db.Scripts {
+		checkScript(script, db.Connection, fmt.Sprintf("%s.Scripts[%d]", field, i), appRoot, problems)
+	}
+}
+
+// checkScript checks a Script has a valid Type, that a path or FS Script matches some files, and
+// that databases can be created and dropped for the connection.
+func checkScript(script Script, conn DBConn, field string, appRoot string, problems *problems) {
+	switch script.Type {
+	case ScriptTypeLiteral, ScriptTypeCleanTables:
+	case ScriptTypeCreateDatabase, ScriptTypeDropDatabase:
+		if script.Command == "" {
+			problems.addf(field, "%s database name has not been set", field)
+		}
+
+		// unregistered drivers are already reported
+		if _, err := dialectFor(conn.Driver); err == nil {
+			if _, err := adminDialectFor(conn.Driver); err != nil {
+				problems.addf(field, "%s: %s", field, err.Error())
+			}
+		}
+	case ScriptTypePath:
+		files, err := filepath.Glob(scriptPath(appRoot, script.Command))
+		if err != nil {
+			problems.addf(field, "%s path \"%s\" is not a valid pattern: %s", field, script.Command, err.Error())
+		} else if len(files) == 0 {
+			problems.addf(field, "%s path \"%s\" doesn't match any files", field, script.Command)
+		}
+	case ScriptTypeFS:
+		if script.FS == nil {
+			problems.addf(field, "%s has no FS, pass one to NewScriptFS or set FixtureConfig.ScriptFS", field)
+			return
+		}
+
+		files, err := fs.Glob(script.FS, script.Command)
+		if err != nil {
+			problems.addf(field, "%s pattern \"%s\" is not a valid pattern: %s", field, script.Command, err.Error())
+		} else if len(files) == 0 {
+			problems.addf(field, "%s pattern \"%s\" doesn't match any files", field, script.Command)
+		}
+	default:
+		problems.addf(field, "%s has an invalid Type %d, use NewScript, NewScriptPath, NewScriptFS, NewCleanTablesScript, NewCreateDatabaseScript or NewDropDatabaseScript", field, script.Type)
+	}
+}